@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/timofvy/multisig/abi/safe_abi"
+)
+
+const defaultAuditChunk = 10000
+
+var errInvalidBlockRange = errors.New("--from must not be greater than --to")
+
+// scanChunks calls scan for consecutive chunks of [from, to]. A chunk the RPC refuses
+// (too many results, timeouts) is halved and retried until it succeeds or shrinks
+// to a single block.
+func scanChunks(from, to, chunk uint64, scan func(start, end uint64) error) error {
+	for start := from; start <= to; {
+		end := min(start+chunk-1, to)
+
+		err := scan(start, end)
+		if err != nil {
+			if chunk == 1 {
+				return fmt.Errorf("blocks %d-%d: %w", start, end, err)
+			}
+
+			chunk /= 2
+			log.Printf("Blocks %d-%d failed (%v), retrying with chunk %d", start, end, err, chunk)
+
+			continue
+		}
+
+		if end == to {
+			break
+		}
+
+		start = end + 1
+	}
+
+	return nil
+}
+
+// scanSafeEvents collects every audited event of the Safe in [from, to] in
+// chronological order without duplicates.
+func scanSafeEvents(filterer *safe_abi.SafeAbiFilterer, from, to, chunk uint64) ([]safeEvent, error) {
+	var events []safeEvent
+
+	seen := map[string]bool{}
+
+	err := scanChunks(from, to, chunk, func(start, end uint64) error {
+		batch, err := filterSafeEvents(filterer, safeEventFilters, start, end)
+		if err != nil {
+			return err
+		}
+
+		for _, event := range batch {
+			key := eventKey(event)
+			if seen[key] {
+				continue
+			}
+
+			seen[key] = true
+			events = append(events, event)
+		}
+
+		log.Printf("Scanned blocks %d-%d: %d events", start, end, len(batch))
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sortSafeEvents(events)
+
+	return events, nil
+}
+
+func filterSafeEvents(
+	filterer *safe_abi.SafeAbiFilterer,
+	filters []safeEventFilter,
+	from, to uint64,
+) ([]safeEvent, error) {
+	opts := &bind.FilterOpts{Start: from, End: &to, Context: context.Background()}
+
+	var events []safeEvent
+
+	for _, f := range filters {
+		batch, err := f.filter(filterer, opts)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.name, err)
+		}
+
+		for _, event := range batch {
+			// Logs dropped by a reorg while the query was served are not part of the history.
+			if !event.Removed {
+				events = append(events, event)
+			}
+		}
+	}
+
+	return events, nil
+}
+
+func addTimestamps(provider *ethclient.Client, events []safeEvent) error {
+	timestamps := map[uint64]uint64{}
+
+	for i := range events {
+		number := events[i].BlockNumber
+
+		timestamp, ok := timestamps[number]
+		if !ok {
+			header, err := provider.HeaderByNumber(context.Background(), new(big.Int).SetUint64(number))
+			if err != nil {
+				return err
+			}
+
+			timestamp = header.Time
+			timestamps[number] = timestamp
+		}
+
+		events[i].Timestamp = timestamp
+	}
+
+	return nil
+}
+
+func writeAuditJSON(out io.Writer, events []safeEvent) error {
+	if events == nil {
+		events = []safeEvent{}
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(events)
+}
+
+// writeAuditCSV writes one row per event; event-specific fields are packed into a
+// single "details" column as sorted key=value pairs so all events share one header.
+func writeAuditCSV(out io.Writer, events []safeEvent) error {
+	writer := csv.NewWriter(out)
+
+	err := writer.Write([]string{"blockNumber", "timestamp", "blockHash", "txHash", "logIndex", "safe", "event", "details"})
+	if err != nil {
+		return err
+	}
+
+	for _, event := range events {
+		keys := make([]string, 0, len(event.Details))
+		for key := range event.Details {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+
+		details := make([]string, 0, len(keys))
+		for _, key := range keys {
+			details = append(details, key+"="+event.Details[key])
+		}
+
+		err = writer.Write([]string{
+			strconv.FormatUint(event.BlockNumber, 10),
+			strconv.FormatUint(event.Timestamp, 10),
+			event.BlockHash,
+			event.TxHash,
+			strconv.FormatUint(uint64(event.LogIndex), 10),
+			event.Safe,
+			event.Event,
+			strings.Join(details, ";"),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+
+	return writer.Error()
+}
+
+func auditCommand() *command {
+	return &command{
+		name:        "audit",
+		args:        "<safe>",
+		description: "Export the event history of a Safe as JSON or CSV",
+		run: func(fs *flag.FlagSet, args []string) error {
+			from := fs.Uint64("from", 0, "First block to scan")
+			to := fs.Uint64("to", 0, "Last block to scan (default latest)")
+			chunk := fs.Uint64("chunk", defaultAuditChunk, "Blocks per eth_getLogs request")
+			format := fs.String("format", "json", "Output format: json or csv")
+			out := fs.String("out", "", "Write the log to a file instead of stdout")
+
+			positional, err := parseArgs(fs, args, 1)
+			if err != nil {
+				return err
+			}
+
+			if *format != "json" && *format != "csv" {
+				return fmt.Errorf("unknown format %q, expected json or csv", *format)
+			}
+
+			if *chunk == 0 {
+				return fmt.Errorf("--chunk must be positive")
+			}
+
+			safe, err := parseAddress(positional[0])
+			if err != nil {
+				return err
+			}
+
+			provider, err := getProvider()
+			if err != nil {
+				return err
+			}
+
+			last := *to
+			if last == 0 {
+				last, err = provider.BlockNumber(context.Background())
+				if err != nil {
+					return err
+				}
+			}
+
+			if *from > last {
+				return errInvalidBlockRange
+			}
+
+			err = checkEventVersion(provider, safe)
+			if err != nil {
+				return err
+			}
+
+			filterer, err := safe_abi.NewSafeAbiFilterer(safe, provider)
+			if err != nil {
+				return err
+			}
+
+			events, err := scanSafeEvents(filterer, *from, last, *chunk)
+			if err != nil {
+				return err
+			}
+
+			err = addTimestamps(provider, events)
+			if err != nil {
+				return err
+			}
+
+			log.Printf("Collected %d events of %s in blocks %d-%d", len(events), safe.Hex(), *from, last)
+
+			output := io.Writer(os.Stdout)
+
+			if *out != "" {
+				file, err := os.OpenFile(*out, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+				if err != nil {
+					return err
+				}
+				defer file.Close()
+
+				output = file
+			}
+
+			if *format == "csv" {
+				return writeAuditCSV(output, events)
+			}
+
+			return writeAuditJSON(output, events)
+		},
+		subcommands: nil,
+	}
+}
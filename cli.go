@@ -0,0 +1,233 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+var errUsage = errors.New("invalid usage")
+
+type command struct {
+	name        string
+	args        string
+	description string
+	run         func(fs *flag.FlagSet, args []string) error
+	subcommands []*command
+}
+
+func commands() []*command {
+	return []*command{
+		deployCommand(),
+		predictCommand(),
+		verifyCommand(),
+		infoCommand(),
+		txCommand(),
+		ownersCommand(),
+		thresholdCommand(),
+		modulesCommand(),
+		guardCommand(),
+		fallbackHandlerCommand(),
+		auditCommand(),
+		watchCommand(),
+		indexCommand(),
+		stuckCommand(),
+		networksCommand(),
+	}
+}
+
+// parseGlobalFlags applies the flags shared by every command on top of the .env configuration
+// and the selected network profile.
+func parseGlobalFlags() error {
+	network := flag.String("network", "", "Network profile from "+defaultNetworksPath+", overrides network")
+	rpcURL := flag.String("rpc", "", "RPC endpoint, overrides rpc_url")
+	chainID := flag.Uint64("chain-id", 0, "Expected chain ID, the command fails if the RPC reports another chain")
+	signer := flag.String("signer", "", "Signer backend: private-key, keystore, mnemonic or external, overrides signer")
+	keystorePath := flag.String("keystore", "", "V3 keystore file of the signer, overrides keystore")
+	passwordFile := flag.String("password-file", "", "File with the keystore password, overrides keystore_password_file")
+	hdPath := flag.String("hd-path", "", "HD derivation path for the mnemonic, overrides hd_path (default "+defaultHDPath+")")
+	externalSigner := flag.String("external-signer", "", "Clef compatible signer endpoint, overrides external_signer")
+	signerAccount := flag.String("signer-address", "", "Account of the external signer to use, overrides signer_address")
+
+	flag.Usage = func() {
+		printCommandList(flag.CommandLine.Output(), "multisig [global flags]", commands())
+		fmt.Fprintln(flag.CommandLine.Output(), "\nGlobal flags:")
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+
+	if *network != "" {
+		viper.Set("network", *network)
+	}
+
+	if name := viper.GetString("network"); name != "" {
+		err := applyNetworkProfile(name)
+		if err != nil {
+			return err
+		}
+	}
+
+	if *rpcURL != "" {
+		viper.Set("rpc_url", *rpcURL)
+	}
+
+	if *chainID != 0 {
+		viper.Set("chain_id", *chainID)
+	}
+
+	for key, value := range map[string]string{
+		"signer":                 *signer,
+		"keystore":               *keystorePath,
+		"keystore_password_file": *passwordFile,
+		"hd_path":                *hdPath,
+		"external_signer":        *externalSigner,
+		"signer_address":         *signerAccount,
+	} {
+		if value != "" {
+			viper.Set(key, value)
+		}
+	}
+
+	return nil
+}
+
+// flagOrConfig returns the flag if it was given, otherwise the config key. It serves flags
+// that default to registry addresses, which are only resolved after the flags are parsed.
+func flagOrConfig(fs *flag.FlagSet, name, key string) string {
+	value := viper.GetString(key)
+
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			value = f.Value.String()
+		}
+	})
+
+	return value
+}
+
+func printCommandList(out io.Writer, usage string, list []*command) {
+	fmt.Fprintf(out, "Usage: %s <command> [flags] [args]\n\nCommands:\n", usage)
+
+	for _, cmd := range list {
+		fmt.Fprintf(out, "  %-18s %s\n", cmd.name, cmd.description)
+	}
+}
+
+func findCommand(list []*command, name string) *command {
+	for _, cmd := range list {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+
+	return nil
+}
+
+func runCommand(prefix string, list []*command, args []string) error {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		if prefix == "multisig" {
+			flag.Usage()
+		} else {
+			printCommandList(os.Stderr, prefix, list)
+		}
+
+		if len(args) == 0 {
+			return errUsage
+		}
+
+		return nil
+	}
+
+	cmd := findCommand(list, args[0])
+	if cmd == nil {
+		return fmt.Errorf("unknown command %q, run \"%s help\"", strings.Join([]string{prefix, args[0]}, " "), prefix)
+	}
+
+	name := prefix + " " + cmd.name
+	if len(cmd.subcommands) > 0 {
+		return runCommand(name, cmd.subcommands, args[1:])
+	}
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s [flags] %s\n\n%s\n", name, cmd.args, cmd.description)
+
+		hasFlags := false
+		fs.VisitAll(func(*flag.Flag) { hasFlags = true })
+
+		if hasFlags {
+			fmt.Fprintln(fs.Output(), "\nFlags:")
+			fs.PrintDefaults()
+		}
+	}
+
+	err := cmd.run(fs, args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+
+	if errors.Is(err, errUsage) {
+		fs.Usage()
+	}
+
+	return err
+}
+
+// parseArgs parses the command flags, allowing them before and after positional arguments,
+// and checks the number of positional arguments.
+func parseArgs(fs *flag.FlagSet, args []string, count int) ([]string, error) {
+	positional, err := parseVarArgs(fs, args, count)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(positional) != count {
+		return nil, errUsage
+	}
+
+	return positional, nil
+}
+
+// parseVarArgs is parseArgs for commands that take at least min positional arguments.
+func parseVarArgs(fs *flag.FlagSet, args []string, minCount int) ([]string, error) {
+	var positional []string
+
+	for {
+		err := fs.Parse(args)
+		if err != nil {
+			return nil, err
+		}
+
+		if fs.NArg() == 0 {
+			break
+		}
+
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+
+	if len(positional) < minCount {
+		return nil, errUsage
+	}
+
+	return positional, nil
+}
+
+// stringList is a flag that can be repeated.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+
+	return nil
+}
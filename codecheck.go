@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/spf13/viper"
+)
+
+// releaseCodeHashes are the Keccak256 hashes of one official Safe release as published in
+// safe-deployments: the runtime code of Safe, SafeL2 and SafeProxyFactory, and the proxy
+// creation code the factory deploys. Code is compared with these constants, so a chain that
+// hosts a tampered copy at the canonical address does not become its own reference.
+type releaseCodeHashes struct {
+	safe          common.Hash
+	safeL2        common.Hash
+	factory       common.Hash
+	proxyCreation common.Hash
+}
+
+// officialCodeHashes are the code hashes by release, for every version in safeVersions.
+var officialCodeHashes = map[string]releaseCodeHashes{
+	"1.3.0": { //nolint:exhaustruct
+		safe:    common.HexToHash("0xbba688fbdb21ad2bb58bc320638b43d94e7d100f6f3ebaab0a4e4de6304b1c2e"),
+		safeL2:  common.HexToHash("0x21842597390c4c6e3c1239e434a682b054bd9548eee5e9b1d6a4482731023c0f"),
+		factory: common.HexToHash("0x337d7f54be11b6ed55fef7b667ea5488db53db8320a05d1146aa4bd169a39a9b"),
+	},
+	"1.4.1": { //nolint:exhaustruct
+		safe: common.HexToHash("0x1fe2df852ba3299d6534ef416eefa406e56ced995bca886ab7a553e6d0c5e1c4"),
+	},
+}
+
+// knownCodeHashes indexes officialCodeHashes by hash, with the contract and release as value.
+func knownCodeHashes() (singletons, factories, proxyCreations map[common.Hash]string) {
+	singletons = map[common.Hash]string{}
+	factories = map[common.Hash]string{}
+	proxyCreations = map[common.Hash]string{}
+
+	for version, release := range officialCodeHashes {
+		for _, entry := range []struct {
+			known map[common.Hash]string
+			hash  common.Hash
+			name  string
+		}{
+			{singletons, release.safe, "Safe"},
+			{singletons, release.safeL2, "SafeL2"},
+			{factories, release.factory, "SafeProxyFactory"},
+			{proxyCreations, release.proxyCreation, "SafeProxy"},
+		} {
+			if entry.hash != (common.Hash{}) {
+				entry.known[entry.hash] = entry.name + " " + version
+			}
+		}
+	}
+
+	return singletons, factories, proxyCreations
+}
+
+// trustedCodeHashes returns the hashes from trusted_code_hashes, for contracts that are not
+// official releases but are trusted anyway, e.g. a local devnet build.
+func trustedCodeHashes() (map[common.Hash]string, error) {
+	trusted := map[common.Hash]string{}
+
+	for _, value := range strings.Split(viper.GetString("trusted_code_hashes"), ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+
+		decoded, err := hexutil.Decode(value)
+		if err != nil || len(decoded) != common.HashLength {
+			return nil, fmt.Errorf("trusted_code_hashes: invalid hash %q", value)
+		}
+
+		trusted[common.BytesToHash(decoded)] = "trusted_code_hashes"
+	}
+
+	return trusted, nil
+}
+
+func codeHashCheck(name string, hash common.Hash, known, trusted map[common.Hash]string) verificationCheck {
+	release, ok := known[hash]
+	if !ok {
+		release, ok = trusted[hash]
+	}
+
+	actual := hash.Hex()
+	if ok {
+		actual += " (" + release + ")"
+	}
+
+	return verificationCheck{name: name, expected: "an official Safe release", actual: actual, ok: ok}
+}
+
+// checkContractCode compares the runtime code of the singleton and the factory, and the
+// proxy creation code of the factory, with the official Safe releases.
+func checkContractCode(provider *ethclient.Client, singleton, factory common.Address) ([]verificationCheck, error) {
+	trusted, err := trustedCodeHashes()
+	if err != nil {
+		return nil, err
+	}
+
+	singletonCodeHashes, factoryCodeHashes, proxyCreationCodeHashes := knownCodeHashes()
+
+	var (
+		checks      []verificationCheck
+		factoryHash common.Hash
+	)
+
+	for _, contract := range []struct {
+		name    string
+		address common.Address
+		known   map[common.Hash]string
+	}{
+		{"singleton code", singleton, singletonCodeHashes},
+		{"factory code", factory, factoryCodeHashes},
+	} {
+		code, err := provider.CodeAt(context.Background(), contract.address, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(code) == 0 {
+			return nil, fmt.Errorf("%s has no contract code at %s", strings.TrimSuffix(contract.name, " code"),
+				contract.address.Hex())
+		}
+
+		hash := crypto.Keccak256Hash(code)
+		if contract.address == factory {
+			factoryHash = hash
+		}
+
+		checks = append(checks, codeHashCheck(contract.name, hash, contract.known, trusted))
+	}
+
+	creationCode, err := getProxyCreationCode(provider, factory)
+	if err != nil {
+		return nil, err
+	}
+
+	creationHash := crypto.Keccak256Hash(creationCode)
+
+	// The proxy creation code is part of the factory code, so a trusted factory vouches for it.
+	if _, ok := trusted[factoryHash]; ok {
+		trusted[creationHash] = "trusted_code_hashes"
+	}
+
+	checks = append(checks, codeHashCheck("proxy creation code", creationHash, proxyCreationCodeHashes, trusted))
+
+	return checks, nil
+}
+
+// verifyContractCode runs checkContractCode before a deployment. A mismatch aborts it
+// unless skip is set, then it is only reported.
+func verifyContractCode(provider *ethclient.Client, singleton, factory common.Address, skip bool) error {
+	checks, err := checkContractCode(provider, singleton, factory)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Code verification of singleton %s and factory %s", singleton.Hex(), factory.Hex())
+
+	failed := 0
+	for _, check := range checks {
+		status := "PASS"
+		if !check.ok {
+			status = "FAIL"
+			failed++
+		}
+
+		log.Printf("  [%s] %s: expected %s, got %s", status, check.name, check.expected, check.actual)
+	}
+
+	if failed == 0 {
+		return nil
+	}
+
+	if skip {
+		log.Println("Warning: the singleton or the factory is not an official Safe release, deploying anyway")
+
+		return nil
+	}
+
+	return fmt.Errorf("the singleton or the factory is not an official Safe release; " +
+		"add its code hash to trusted_code_hashes if you trust it, or use --skip-code-check")
+}
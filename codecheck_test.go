@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestOfficialCodeHashesCoverDeployments(t *testing.T) {
+	t.Parallel()
+
+	deployments, err := loadDeployments()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checked := map[safeDeployment]bool{}
+
+	for chainID, chain := range deployments {
+		for version, deployment := range chain.Versions {
+			if checked[deployment] {
+				continue
+			}
+
+			checked[deployment] = true
+
+			release, ok := officialCodeHashes[version]
+			if !ok {
+				t.Errorf("chain %s: no code hashes for Safe %s", chainID, version)
+
+				continue
+			}
+
+			for _, contract := range []struct {
+				name    string
+				address string
+				hash    common.Hash
+			}{
+				{"Safe", deployment.Safe, release.safe},
+				{"SafeL2", deployment.SafeL2, release.safeL2},
+				{"SafeProxyFactory", deployment.SafeProxyFactory, release.factory},
+				{"SafeProxy creation code", deployment.SafeProxyFactory, release.proxyCreation},
+			} {
+				if contract.hash == (common.Hash{}) {
+					t.Errorf("chain %s: no code hash for %s %s at %s", chainID, contract.name, version, contract.address)
+				}
+			}
+		}
+	}
+
+	for _, version := range safeVersions {
+		if _, ok := officialCodeHashes[version]; !ok {
+			t.Errorf("no code hashes for Safe %s", version)
+		}
+	}
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/spf13/viper"
+	"github.com/timofvy/multisig/abi/safe_proxy_factory_abi"
+)
+
+type saltFlags struct {
+	saltNonce *string
+	autoSalt  *bool
+}
+
+func addSaltFlags(fs *flag.FlagSet) saltFlags {
+	return saltFlags{
+		saltNonce: fs.String("salt-nonce", "0", "Salt nonce passed to CreateProxyWithNonce"),
+		autoSalt:  fs.Bool("auto-salt", false, "Pick the next free salt nonce if the predicted address is taken"),
+	}
+}
+
+func deployCommand() *command {
+	return &command{
+		name:        "deploy",
+		args:        "",
+		description: "Deploy a new Safe through the proxy factory",
+		run: func(fs *flag.FlagSet, args []string) error {
+			setup := addSetupFlags(fs)
+			salt := addSaltFlags(fs)
+			confirmations := fs.Uint64("confirmations", 1, "Number of confirmations to wait for")
+			timeout := fs.Duration("timeout", 5*time.Minute, "How long to wait for the deployment to be confirmed")
+			dryRun := fs.Bool("dry-run", false, "Simulate the deployment with eth_call and gas estimation without sending")
+			skipCodeCheck := fs.Bool("skip-code-check", false,
+				"Deploy even if the singleton or the factory code does not match an official Safe release")
+			feeFlags := addFeeFlags(fs)
+
+			_, err := parseArgs(fs, args, 0)
+			if err != nil {
+				return err
+			}
+
+			err = applyDeploymentRegistry()
+			if err != nil {
+				return err
+			}
+
+			params, err := setup.params()
+			if err != nil {
+				return err
+			}
+
+			saltNonce, err := parseSaltNonce(*salt.saltNonce)
+			if err != nil {
+				return err
+			}
+
+			fees, err := feeFlags.settings()
+			if err != nil {
+				return err
+			}
+
+			if *dryRun {
+				return dryRunDeployMultisig(params, saltNonce, *salt.autoSalt, fees, *skipCodeCheck)
+			}
+
+			return sendDeployMultisig(params, saltNonce, *salt.autoSalt, fees, *skipCodeCheck, *confirmations, *timeout)
+		},
+		subcommands: nil,
+	}
+}
+
+func predictCommand() *command {
+	return &command{
+		name:        "predict",
+		args:        "",
+		description: "Print the predicted Safe address without deploying",
+		run: func(fs *flag.FlagSet, args []string) error {
+			setup := addSetupFlags(fs)
+			salt := addSaltFlags(fs)
+
+			_, err := parseArgs(fs, args, 0)
+			if err != nil {
+				return err
+			}
+
+			err = applyDeploymentRegistry()
+			if err != nil {
+				return err
+			}
+
+			params, err := setup.params()
+			if err != nil {
+				return err
+			}
+
+			saltNonce, err := parseSaltNonce(*salt.saltNonce)
+			if err != nil {
+				return err
+			}
+
+			return predictMultisig(params, saltNonce, *salt.autoSalt)
+		},
+		subcommands: nil,
+	}
+}
+
+func verifyCommand() *command {
+	return &command{
+		name:        "verify",
+		args:        "<safe>",
+		description: "Verify a freshly deployed Safe against the expected setup",
+		run: func(fs *flag.FlagSet, args []string) error {
+			setup := addSetupFlags(fs)
+
+			positional, err := parseArgs(fs, args, 1)
+			if err != nil {
+				return err
+			}
+
+			err = applyDeploymentRegistry()
+			if err != nil {
+				return err
+			}
+
+			safe, err := parseAddress(positional[0])
+			if err != nil {
+				return err
+			}
+
+			params, err := setup.params()
+			if err != nil {
+				return err
+			}
+
+			return verifyMultisig(safe, params)
+		},
+		subcommands: nil,
+	}
+}
+
+func predictMultisig(
+	params setupParams,
+	saltNonce *big.Int,
+	autoSalt bool,
+) error {
+	err := checkDeployParams(params)
+	if err != nil {
+		return err
+	}
+
+	safeProxyFactoryAddress := common.HexToAddress(viper.GetString("safe_proxy_factory"))
+	safeAddress := common.HexToAddress(viper.GetString("safe"))
+
+	data, err := packSetup(params)
+	if err != nil {
+		return err
+	}
+
+	provider, err := getProvider()
+	if err != nil {
+		return err
+	}
+
+	proxyCreationCode, err := getProxyCreationCode(provider, safeProxyFactoryAddress)
+	if err != nil {
+		return err
+	}
+
+	saltNonce, predicted, err := resolveSaltNonce(
+		provider,
+		safeProxyFactoryAddress,
+		proxyCreationCode,
+		safeAddress,
+		data,
+		saltNonce,
+		autoSalt,
+	)
+	if err != nil {
+		return err
+	}
+
+	log.Println("Salt nonce: ", saltNonce.String())
+	log.Println("Predicted Safe address: ", predicted.Hex())
+
+	return nil
+}
+
+func verifyMultisig(
+	safe common.Address,
+	params setupParams,
+) error {
+	err := checkDeployParams(params)
+	if err != nil {
+		return err
+	}
+
+	provider, err := getProvider()
+	if err != nil {
+		return err
+	}
+
+	checks, err := verifySafe(provider, safe, expectedSafe{
+		owners:          params.owners,
+		threshold:       params.threshold,
+		singleton:       common.HexToAddress(viper.GetString("safe")),
+		fallbackHandler: params.fallbackHandler,
+	})
+	if err != nil {
+		return err
+	}
+
+	return reportVerification(safe, checks)
+}
+
+func sendDeployMultisig(
+	params setupParams,
+	saltNonce *big.Int,
+	autoSalt bool,
+	fees feeSettings,
+	skipCodeCheck bool,
+	confirmations uint64,
+	timeout time.Duration,
+) error {
+	err := checkDeployParams(params)
+	if err != nil {
+		return err
+	}
+
+	safeProxyFactoryAddress := common.HexToAddress(viper.GetString("safe_proxy_factory"))
+	safeAddress := common.HexToAddress(viper.GetString("safe"))
+
+	data, err := packSetup(params)
+	if err != nil {
+		return err
+	}
+
+	provider, err := getProvider()
+	if err != nil {
+		return err
+	}
+
+	err = verifyContractCode(provider, safeAddress, safeProxyFactoryAddress, skipCodeCheck)
+	if err != nil {
+		return err
+	}
+
+	proxyCreationCode, err := getProxyCreationCode(provider, safeProxyFactoryAddress)
+	if err != nil {
+		return err
+	}
+
+	saltNonce, predicted, err := resolveSaltNonce(
+		provider,
+		safeProxyFactoryAddress,
+		proxyCreationCode,
+		safeAddress,
+		data,
+		saltNonce,
+		autoSalt,
+	)
+	if err != nil {
+		return err
+	}
+
+	log.Println("Salt nonce: ", saltNonce.String())
+	log.Println("Predicted Safe address: ", predicted.Hex())
+
+	err = checkSetupOnChain(provider, params, predicted)
+	if err != nil {
+		return err
+	}
+
+	contractTransactor, err := safe_proxy_factory_abi.NewSafeProxyFactoryAbiTransactor(
+		safeProxyFactoryAddress,
+		provider,
+	)
+	if err != nil {
+		return err
+	}
+
+	trOpts, err := newTransactOpts(provider)
+	if err != nil {
+		return err
+	}
+
+	transaction, err := fees.transact(provider, trOpts, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return contractTransactor.CreateProxyWithNonce(opts, safeAddress, data, saltNonce)
+	})
+	if err != nil {
+		return fmt.Errorf("create proxy: %s", decodeRevert(err))
+	}
+
+	log.Println("Transaction sent: ", transaction.Hash().Hex())
+
+	receipt, err := waitForReceipt(provider, transaction, confirmations, timeout)
+	if err != nil {
+		return err
+	}
+
+	reportReceipt(receipt)
+
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return fmt.Errorf("transaction %s reverted", transaction.Hash().Hex())
+	}
+
+	creation, err := parseProxyCreation(provider, safeProxyFactoryAddress, receipt)
+	if err != nil {
+		return err
+	}
+
+	log.Println("Safe deployed: ", creation.Proxy.Hex())
+
+	if creation.Proxy != predicted {
+		return fmt.Errorf(
+			"deployed Safe %s does not match predicted address %s",
+			creation.Proxy.Hex(),
+			predicted.Hex(),
+		)
+	}
+
+	checks, err := verifySafe(provider, creation.Proxy, expectedSafe{
+		owners:          params.owners,
+		threshold:       params.threshold,
+		singleton:       safeAddress,
+		fallbackHandler: params.fallbackHandler,
+	})
+	if err != nil {
+		return err
+	}
+
+	return reportVerification(creation.Proxy, checks)
+}
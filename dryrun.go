@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/spf13/viper"
+	"github.com/timofvy/multisig/abi/safe_proxy_factory_abi"
+)
+
+func formatEther(wei *big.Int) string {
+	ether := new(big.Float).Quo(new(big.Float).SetInt(wei), big.NewFloat(params.Ether))
+
+	return ether.Text('f', 18)
+}
+
+// dryRunDeployMultisig simulates CreateProxyWithNonce with eth_call and eth_estimateGas.
+// Nothing is signed or broadcast, the private key is only used to derive the sender.
+func dryRunDeployMultisig(
+	params setupParams,
+	saltNonce *big.Int,
+	autoSalt bool,
+	fees feeSettings,
+	skipCodeCheck bool,
+) error {
+	err := checkDeployParams(params)
+	if err != nil {
+		return err
+	}
+
+	safeProxyFactoryAddress := common.HexToAddress(viper.GetString("safe_proxy_factory"))
+	safeAddress := common.HexToAddress(viper.GetString("safe"))
+
+	data, err := packSetup(params)
+	if err != nil {
+		return err
+	}
+
+	provider, err := getProvider()
+	if err != nil {
+		return err
+	}
+
+	err = verifyContractCode(provider, safeAddress, safeProxyFactoryAddress, skipCodeCheck)
+	if err != nil {
+		return err
+	}
+
+	proxyCreationCode, err := getProxyCreationCode(provider, safeProxyFactoryAddress)
+	if err != nil {
+		return err
+	}
+
+	saltNonce, predicted, err := resolveSaltNonce(
+		provider,
+		safeProxyFactoryAddress,
+		proxyCreationCode,
+		safeAddress,
+		data,
+		saltNonce,
+		autoSalt,
+	)
+	if err != nil {
+		return err
+	}
+
+	log.Println("Salt nonce: ", saltNonce.String())
+	log.Println("Predicted Safe address: ", predicted.Hex())
+
+	err = checkSetupOnChain(provider, params, predicted)
+	if err != nil {
+		return err
+	}
+
+	factoryAbi, err := abi.JSON(strings.NewReader(safe_proxy_factory_abi.SafeProxyFactoryAbiABI))
+	if err != nil {
+		return err
+	}
+
+	calldata, err := factoryAbi.Pack("createProxyWithNonce", safeAddress, data, saltNonce)
+	if err != nil {
+		return err
+	}
+
+	from, err := signerAddress()
+	if err != nil {
+		return err
+	}
+
+	msg := ethereum.CallMsg{ //nolint:exhaustruct
+		From: from,
+		To:   &safeProxyFactoryAddress,
+		Data: calldata,
+	}
+
+	result, err := provider.CallContract(context.Background(), msg, nil)
+	if err != nil {
+		return fmt.Errorf("simulation reverted: %s", decodeRevert(err))
+	}
+
+	proxy, err := factoryAbi.Unpack("createProxyWithNonce", result)
+	if err != nil {
+		return err
+	}
+
+	if len(proxy) == 1 {
+		if simulated, ok := proxy[0].(common.Address); ok && simulated != predicted {
+			return fmt.Errorf("simulated Safe %s does not match predicted address %s", simulated.Hex(), predicted.Hex())
+		}
+	}
+
+	gas, err := provider.EstimateGas(context.Background(), msg)
+	if err != nil {
+		return fmt.Errorf("gas estimation failed: %s", decodeRevert(err))
+	}
+
+	quote, err := fees.quote(provider, gas)
+	if err != nil {
+		return err
+	}
+
+	log.Println("Simulation succeeded, sender: ", from.Hex())
+	log.Println("Gas estimate: ", gas)
+	quote.report(nil)
+
+	return fees.checkSpend(quote.maxCost(nil))
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/timofvy/multisig/abi/safe_abi"
+)
+
+// Type hashes of the Safe EIP-712 domain and SafeTx struct, see Safe.sol (v1.3.0 and later).
+var (
+	domainSeparatorTypehash = crypto.Keccak256Hash(
+		[]byte("EIP712Domain(uint256 chainId,address verifyingContract)"),
+	)
+	safeTxTypehash = crypto.Keccak256Hash([]byte(
+		"SafeTx(address to,uint256 value,bytes data,uint8 operation,uint256 safeTxGas,uint256 baseGas," +
+			"uint256 gasPrice,address gasToken,address refundReceiver,uint256 nonce)",
+	))
+)
+
+func word(value []byte) []byte {
+	return common.LeftPadBytes(value, 32)
+}
+
+func safeDomainSeparator(chainID *big.Int, safe common.Address) common.Hash {
+	return crypto.Keccak256Hash(
+		domainSeparatorTypehash.Bytes(),
+		word(chainID.Bytes()),
+		word(safe.Bytes()),
+	)
+}
+
+func safeTxStructHash(tx safeTx) common.Hash {
+	return crypto.Keccak256Hash(
+		safeTxTypehash.Bytes(),
+		word(tx.to.Bytes()),
+		word(tx.value.Bytes()),
+		crypto.Keccak256(tx.data),
+		word([]byte{tx.operation}),
+		word(tx.safeTxGas.Bytes()),
+		word(tx.baseGas.Bytes()),
+		word(tx.gasPrice.Bytes()),
+		word(tx.gasToken.Bytes()),
+		word(tx.refundReceiver.Bytes()),
+		word(tx.nonce.Bytes()),
+	)
+}
+
+// encodeSafeTxData mirrors Safe.encodeTransactionData: 0x19 0x01 domainSeparator safeTxStructHash.
+func encodeSafeTxData(chainID *big.Int, safe common.Address, tx safeTx) []byte {
+	data := make([]byte, 0, 66)
+	data = append(data, 0x19, 0x01)
+	data = append(data, safeDomainSeparator(chainID, safe).Bytes()...)
+	data = append(data, safeTxStructHash(tx).Bytes()...)
+
+	return data
+}
+
+// computeSafeTxHash mirrors Safe.getTransactionHash without any RPC access.
+func computeSafeTxHash(chainID *big.Int, safe common.Address, tx safeTx) common.Hash {
+	return crypto.Keccak256Hash(encodeSafeTxData(chainID, safe, tx))
+}
+
+// crossCheckSafeTxHash compares the local EIP-712 encoding with DomainSeparator,
+// EncodeTransactionData and GetTransactionHash of the deployed Safe.
+func crossCheckSafeTxHash(
+	caller *safe_abi.SafeAbiCaller,
+	chainID *big.Int,
+	safe common.Address,
+	tx safeTx,
+) (common.Hash, error) {
+	opts := &bind.CallOpts{Context: context.Background()} //nolint:exhaustruct
+
+	hash := computeSafeTxHash(chainID, safe, tx)
+
+	domainSeparator, err := caller.DomainSeparator(opts)
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	if local := safeDomainSeparator(chainID, safe); common.Hash(domainSeparator) != local {
+		return common.Hash{}, fmt.Errorf(
+			"domain separator mismatch: local %s, on-chain %s",
+			local.Hex(),
+			common.Hash(domainSeparator).Hex(),
+		)
+	}
+
+	encoded, err := caller.EncodeTransactionData(
+		opts,
+		tx.to,
+		tx.value,
+		tx.data,
+		tx.operation,
+		tx.safeTxGas,
+		tx.baseGas,
+		tx.gasPrice,
+		tx.gasToken,
+		tx.refundReceiver,
+		tx.nonce,
+	)
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	if local := encodeSafeTxData(chainID, safe, tx); !bytes.Equal(encoded, local) {
+		return common.Hash{}, fmt.Errorf(
+			"transaction data mismatch: local %s, on-chain %s",
+			hexutil.Encode(local),
+			hexutil.Encode(encoded),
+		)
+	}
+
+	onChainHash, err := getSafeTxHash(caller, tx)
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	if onChainHash != hash {
+		return common.Hash{}, fmt.Errorf(
+			"transaction hash mismatch: local %s, on-chain %s",
+			hash.Hex(),
+			onChainHash.Hex(),
+		)
+	}
+
+	return hash, nil
+}
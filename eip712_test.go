@@ -0,0 +1,91 @@
+package main
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// The vectors were produced by DomainSeparator, EncodeTransactionData and GetTransactionHash
+// of the official Safe singleton deployed on a simulated chain (chain ID 1337) at the
+// first contract address of the deployer. The SafeTx encoding is the same in v1.3.0 and v1.4.1.
+var (
+	vectorChainID = big.NewInt(1337)
+	vectorSafe    = common.HexToAddress("0x5FbDB2315678afecb367f032d93F642f64180aa3")
+)
+
+func TestSafeDomainSeparator(t *testing.T) {
+	t.Parallel()
+
+	want := common.HexToHash("0x59d4f531a7b24ce2d99cf79c3b685ea5d9d94c75d5e54c352f3cf4431b276892")
+
+	if got := safeDomainSeparator(vectorChainID, vectorSafe); got != want {
+		t.Fatalf("domain separator %s, want %s", got.Hex(), want.Hex())
+	}
+}
+
+func TestSafeTxHash(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		tx      safeTx
+		encoded string
+		hash    string
+	}{
+		{
+			name: "ether transfer",
+			tx: safeTx{
+				to:             common.HexToAddress("0x70997970C51812dc3A010C7d01b50e0d17dc79C8"),
+				value:          big.NewInt(1e18),
+				data:           nil,
+				operation:      operationCall,
+				safeTxGas:      big.NewInt(0),
+				baseGas:        big.NewInt(0),
+				gasPrice:       big.NewInt(0),
+				gasToken:       common.Address{},
+				refundReceiver: common.Address{},
+				nonce:          big.NewInt(0),
+			},
+			encoded: "0x190159d4f531a7b24ce2d99cf79c3b685ea5d9d94c75d5e54c352f3cf4431b276892" +
+				"cb97f90e503dd8c16c9c5f4d5054a618d7a5eec93397b8656a942a3d1db640d5",
+			hash: "0x23e4e9cb9be1c0eb1ca6cec92e39a3f0f561d6235b4ed75b6217ba12c9468af2",
+		},
+		{
+			name: "delegatecall with refund",
+			tx: safeTx{
+				to:    common.HexToAddress("0x38869bf66a61cF6bDB996A6aE40D5853Fd43B526"),
+				value: big.NewInt(0),
+				data: hexutil.MustDecode("0x8d80ff0a" +
+					"0000000000000000000000000000000000000000000000000000000000000020" +
+					"0000000000000000000000000000000000000000000000000000000000000000"),
+				operation:      operationDelegateCall,
+				safeTxGas:      big.NewInt(50000),
+				baseGas:        big.NewInt(21000),
+				gasPrice:       big.NewInt(1000000000),
+				gasToken:       common.HexToAddress("0x6B175474E89094C44Da98b954EedeAC495271d0F"),
+				refundReceiver: common.HexToAddress("0x3C44CdDdB6a900fa2b585dd299e03d12FA4293BC"),
+				nonce:          big.NewInt(7),
+			},
+			encoded: "0x190159d4f531a7b24ce2d99cf79c3b685ea5d9d94c75d5e54c352f3cf4431b276892" +
+				"6521567bf0ca6a17e4e789b94c72567319fcd22430747e1cf5be2b98a8321434",
+			hash: "0x6697f075be8583847771b308ceaeb8f284374e129d021c186d875fdde1c96cb7",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := hexutil.Encode(encodeSafeTxData(vectorChainID, vectorSafe, test.tx)); got != test.encoded {
+				t.Errorf("encoded %s, want %s", got, test.encoded)
+			}
+
+			if got := computeSafeTxHash(vectorChainID, vectorSafe, test.tx); got != common.HexToHash(test.hash) {
+				t.Errorf("hash %s, want %s", got.Hex(), test.hash)
+			}
+		})
+	}
+}
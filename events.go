@@ -0,0 +1,457 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/timofvy/multisig/abi/safe_abi"
+)
+
+// safeEvent is a decoded Safe event in a flat form suitable for JSON and CSV export.
+type safeEvent struct {
+	BlockNumber uint64            `json:"blockNumber"`
+	BlockHash   string            `json:"blockHash"`
+	Timestamp   uint64            `json:"timestamp,omitempty"`
+	TxHash      string            `json:"txHash"`
+	LogIndex    uint              `json:"logIndex"`
+	Safe        string            `json:"safe"`
+	Event       string            `json:"event"`
+	Details     map[string]string `json:"details"`
+	Removed     bool              `json:"removed,omitempty"`
+}
+
+// legacyEventVersion reports whether a Safe release predates 1.4.0. Those releases index
+// no event fields, so the 1.4.1 bindings cannot decode their events.
+func legacyEventVersion(version string) bool {
+	var major, minor int
+
+	_, err := fmt.Sscanf(version, "%d.%d", &major, &minor)
+
+	return err == nil && (major < 1 || major == 1 && minor < 4)
+}
+
+// checkEventVersion fails for a Safe whose events the bindings cannot decode.
+func checkEventVersion(provider *ethclient.Client, safe common.Address) error {
+	caller, err := safe_abi.NewSafeAbiCaller(safe, provider)
+	if err != nil {
+		return err
+	}
+
+	version, err := caller.VERSION(&bind.CallOpts{Context: context.Background()}) //nolint:exhaustruct
+	if err != nil {
+		return fmt.Errorf("safe %s: %w", safe.Hex(), err)
+	}
+
+	if legacyEventVersion(version) {
+		return fmt.Errorf("safe %s is version %s, its events have no indexed fields and cannot be decoded; "+
+			"only Safe 1.4.0 and later are supported", safe.Hex(), version)
+	}
+
+	return nil
+}
+
+// checkFactoryEventVersion fails for a registry factory of a release before 1.4.0, whose
+// ProxyCreation event has no indexed proxy. Factories have no VERSION to ask.
+func checkFactoryEventVersion(factory common.Address) error {
+	deployments, err := loadDeployments()
+	if err != nil {
+		return err
+	}
+
+	for _, chain := range deployments {
+		for version, deployment := range chain.Versions {
+			if legacyEventVersion(version) && common.HexToAddress(deployment.SafeProxyFactory) == factory {
+				return fmt.Errorf("factory %s is the Safe %s factory, its ProxyCreation events cannot be decoded; "+
+					"only Safe 1.4.0 and later are supported", factory.Hex(), version)
+			}
+		}
+	}
+
+	return nil
+}
+
+// legacyEventData returns the two data words of an event of a release before 1.4.0, where
+// all fields of ProxyCreation, ExecutionSuccess and ExecutionFailure are in the data.
+func legacyEventData(raw types.Log, signature string) (common.Hash, common.Hash, bool) {
+	if len(raw.Topics) != 1 || raw.Topics[0] != crypto.Keccak256Hash([]byte(signature)) || len(raw.Data) != 64 {
+		return common.Hash{}, common.Hash{}, false
+	}
+
+	return common.BytesToHash(raw.Data[:32]), common.BytesToHash(raw.Data[32:]), true
+}
+
+func newSafeEvent(name string, raw types.Log, details map[string]string) safeEvent {
+	return safeEvent{
+		BlockNumber: raw.BlockNumber,
+		BlockHash:   raw.BlockHash.Hex(),
+		TxHash:      raw.TxHash.Hex(),
+		LogIndex:    raw.Index,
+		Safe:        raw.Address.Hex(),
+		Event:       name,
+		Details:     details,
+		Removed:     raw.Removed,
+	}
+}
+
+func eventKey(e safeEvent) string {
+	return e.TxHash + ":" + strconv.FormatUint(uint64(e.LogIndex), 10)
+}
+
+// sortSafeEvents orders events chronologically by block and log index.
+func sortSafeEvents(events []safeEvent) {
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].BlockNumber != events[j].BlockNumber {
+			return events[i].BlockNumber < events[j].BlockNumber
+		}
+
+		return events[i].LogIndex < events[j].LogIndex
+	})
+}
+
+type logIterator interface {
+	Next() bool
+	Error() error
+	Close() error
+}
+
+func drain(it logIterator, read func() safeEvent) ([]safeEvent, error) {
+	defer it.Close()
+
+	var events []safeEvent
+	for it.Next() {
+		events = append(events, read())
+	}
+
+	return events, it.Error()
+}
+
+// watchSafeEvent subscribes with a typed Watch* function and forwards every log,
+// converted to a safeEvent, into the shared sink until unsubscribed.
+func watchSafeEvent[T any](
+	subscribe func(chan<- T) (event.Subscription, error),
+	sink chan<- safeEvent,
+	convert func(T) safeEvent,
+) (event.Subscription, error) {
+	logs := make(chan T)
+
+	sub, err := subscribe(logs)
+	if err != nil {
+		return nil, err
+	}
+
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer sub.Unsubscribe()
+
+		for {
+			select {
+			case value := <-logs:
+				select {
+				case sink <- convert(value):
+				case <-quit:
+					return nil
+				}
+			case err := <-sub.Err():
+				return err
+			case <-quit:
+				return nil
+			}
+		}
+	}), nil
+}
+
+func addedOwnerEvent(e *safe_abi.SafeAbiAddedOwner) safeEvent {
+	return newSafeEvent("AddedOwner", e.Raw, map[string]string{"owner": e.Owner.Hex()})
+}
+
+func removedOwnerEvent(e *safe_abi.SafeAbiRemovedOwner) safeEvent {
+	return newSafeEvent("RemovedOwner", e.Raw, map[string]string{"owner": e.Owner.Hex()})
+}
+
+func changedThresholdEvent(e *safe_abi.SafeAbiChangedThreshold) safeEvent {
+	return newSafeEvent("ChangedThreshold", e.Raw, map[string]string{"threshold": e.Threshold.String()})
+}
+
+func enabledModuleEvent(e *safe_abi.SafeAbiEnabledModule) safeEvent {
+	return newSafeEvent("EnabledModule", e.Raw, map[string]string{"module": e.Module.Hex()})
+}
+
+func disabledModuleEvent(e *safe_abi.SafeAbiDisabledModule) safeEvent {
+	return newSafeEvent("DisabledModule", e.Raw, map[string]string{"module": e.Module.Hex()})
+}
+
+func changedGuardEvent(e *safe_abi.SafeAbiChangedGuard) safeEvent {
+	return newSafeEvent("ChangedGuard", e.Raw, map[string]string{"guard": e.Guard.Hex()})
+}
+
+func changedFallbackHandlerEvent(e *safe_abi.SafeAbiChangedFallbackHandler) safeEvent {
+	return newSafeEvent("ChangedFallbackHandler", e.Raw, map[string]string{"handler": e.Handler.Hex()})
+}
+
+func executionSuccessEvent(e *safe_abi.SafeAbiExecutionSuccess) safeEvent {
+	return newSafeEvent("ExecutionSuccess", e.Raw, map[string]string{
+		"safeTxHash": common.Hash(e.TxHash).Hex(),
+		"payment":    e.Payment.String(),
+	})
+}
+
+func executionFailureEvent(e *safe_abi.SafeAbiExecutionFailure) safeEvent {
+	return newSafeEvent("ExecutionFailure", e.Raw, map[string]string{
+		"safeTxHash": common.Hash(e.TxHash).Hex(),
+		"payment":    e.Payment.String(),
+	})
+}
+
+func safeReceivedEvent(e *safe_abi.SafeAbiSafeReceived) safeEvent {
+	return newSafeEvent("SafeReceived", e.Raw, map[string]string{
+		"sender": e.Sender.Hex(),
+		"value":  e.Value.String(),
+	})
+}
+
+func approveHashEvent(e *safe_abi.SafeAbiApproveHash) safeEvent {
+	return newSafeEvent("ApproveHash", e.Raw, map[string]string{
+		"approvedHash": common.Hash(e.ApprovedHash).Hex(),
+		"owner":        e.Owner.Hex(),
+	})
+}
+
+func signMsgEvent(e *safe_abi.SafeAbiSignMsg) safeEvent {
+	return newSafeEvent("SignMsg", e.Raw, map[string]string{"msgHash": common.Hash(e.MsgHash).Hex()})
+}
+
+func safeSetupEvent(e *safe_abi.SafeAbiSafeSetup) safeEvent {
+	return newSafeEvent("SafeSetup", e.Raw, map[string]string{
+		"initiator":       e.Initiator.Hex(),
+		"owners":          strings.Join(hexAddresses(e.Owners), " "),
+		"threshold":       e.Threshold.String(),
+		"initializer":     e.Initializer.Hex(),
+		"fallbackHandler": e.FallbackHandler.Hex(),
+	})
+}
+
+type safeEventFilter struct {
+	name   string
+	filter func(f *safe_abi.SafeAbiFilterer, opts *bind.FilterOpts) ([]safeEvent, error)
+	watch  func(f *safe_abi.SafeAbiFilterer, opts *bind.WatchOpts, sink chan<- safeEvent) (event.Subscription, error)
+}
+
+// safeEventFilters lists the tracked Safe events with their typed Filter* and Watch* bindings.
+var safeEventFilters = []safeEventFilter{
+	{
+		name: "AddedOwner",
+		filter: func(f *safe_abi.SafeAbiFilterer, opts *bind.FilterOpts) ([]safeEvent, error) {
+			it, err := f.FilterAddedOwner(opts, nil)
+			if err != nil {
+				return nil, err
+			}
+
+			return drain(it, func() safeEvent { return addedOwnerEvent(it.Event) })
+		},
+		watch: func(f *safe_abi.SafeAbiFilterer, opts *bind.WatchOpts, sink chan<- safeEvent) (event.Subscription, error) {
+			return watchSafeEvent(func(logs chan<- *safe_abi.SafeAbiAddedOwner) (event.Subscription, error) {
+				return f.WatchAddedOwner(opts, logs, nil)
+			}, sink, addedOwnerEvent)
+		},
+	},
+	{
+		name: "RemovedOwner",
+		filter: func(f *safe_abi.SafeAbiFilterer, opts *bind.FilterOpts) ([]safeEvent, error) {
+			it, err := f.FilterRemovedOwner(opts, nil)
+			if err != nil {
+				return nil, err
+			}
+
+			return drain(it, func() safeEvent { return removedOwnerEvent(it.Event) })
+		},
+		watch: func(f *safe_abi.SafeAbiFilterer, opts *bind.WatchOpts, sink chan<- safeEvent) (event.Subscription, error) {
+			return watchSafeEvent(func(logs chan<- *safe_abi.SafeAbiRemovedOwner) (event.Subscription, error) {
+				return f.WatchRemovedOwner(opts, logs, nil)
+			}, sink, removedOwnerEvent)
+		},
+	},
+	{
+		name: "ChangedThreshold",
+		filter: func(f *safe_abi.SafeAbiFilterer, opts *bind.FilterOpts) ([]safeEvent, error) {
+			it, err := f.FilterChangedThreshold(opts)
+			if err != nil {
+				return nil, err
+			}
+
+			return drain(it, func() safeEvent { return changedThresholdEvent(it.Event) })
+		},
+		watch: func(f *safe_abi.SafeAbiFilterer, opts *bind.WatchOpts, sink chan<- safeEvent) (event.Subscription, error) {
+			return watchSafeEvent(func(logs chan<- *safe_abi.SafeAbiChangedThreshold) (event.Subscription, error) {
+				return f.WatchChangedThreshold(opts, logs)
+			}, sink, changedThresholdEvent)
+		},
+	},
+	{
+		name: "EnabledModule",
+		filter: func(f *safe_abi.SafeAbiFilterer, opts *bind.FilterOpts) ([]safeEvent, error) {
+			it, err := f.FilterEnabledModule(opts, nil)
+			if err != nil {
+				return nil, err
+			}
+
+			return drain(it, func() safeEvent { return enabledModuleEvent(it.Event) })
+		},
+		watch: func(f *safe_abi.SafeAbiFilterer, opts *bind.WatchOpts, sink chan<- safeEvent) (event.Subscription, error) {
+			return watchSafeEvent(func(logs chan<- *safe_abi.SafeAbiEnabledModule) (event.Subscription, error) {
+				return f.WatchEnabledModule(opts, logs, nil)
+			}, sink, enabledModuleEvent)
+		},
+	},
+	{
+		name: "DisabledModule",
+		filter: func(f *safe_abi.SafeAbiFilterer, opts *bind.FilterOpts) ([]safeEvent, error) {
+			it, err := f.FilterDisabledModule(opts, nil)
+			if err != nil {
+				return nil, err
+			}
+
+			return drain(it, func() safeEvent { return disabledModuleEvent(it.Event) })
+		},
+		watch: func(f *safe_abi.SafeAbiFilterer, opts *bind.WatchOpts, sink chan<- safeEvent) (event.Subscription, error) {
+			return watchSafeEvent(func(logs chan<- *safe_abi.SafeAbiDisabledModule) (event.Subscription, error) {
+				return f.WatchDisabledModule(opts, logs, nil)
+			}, sink, disabledModuleEvent)
+		},
+	},
+	{
+		name: "ChangedGuard",
+		filter: func(f *safe_abi.SafeAbiFilterer, opts *bind.FilterOpts) ([]safeEvent, error) {
+			it, err := f.FilterChangedGuard(opts, nil)
+			if err != nil {
+				return nil, err
+			}
+
+			return drain(it, func() safeEvent { return changedGuardEvent(it.Event) })
+		},
+		watch: func(f *safe_abi.SafeAbiFilterer, opts *bind.WatchOpts, sink chan<- safeEvent) (event.Subscription, error) {
+			return watchSafeEvent(func(logs chan<- *safe_abi.SafeAbiChangedGuard) (event.Subscription, error) {
+				return f.WatchChangedGuard(opts, logs, nil)
+			}, sink, changedGuardEvent)
+		},
+	},
+	{
+		name: "ChangedFallbackHandler",
+		filter: func(f *safe_abi.SafeAbiFilterer, opts *bind.FilterOpts) ([]safeEvent, error) {
+			it, err := f.FilterChangedFallbackHandler(opts, nil)
+			if err != nil {
+				return nil, err
+			}
+
+			return drain(it, func() safeEvent { return changedFallbackHandlerEvent(it.Event) })
+		},
+		watch: func(f *safe_abi.SafeAbiFilterer, opts *bind.WatchOpts, sink chan<- safeEvent) (event.Subscription, error) {
+			return watchSafeEvent(func(logs chan<- *safe_abi.SafeAbiChangedFallbackHandler) (event.Subscription, error) {
+				return f.WatchChangedFallbackHandler(opts, logs, nil)
+			}, sink, changedFallbackHandlerEvent)
+		},
+	},
+	{
+		name: "ExecutionSuccess",
+		filter: func(f *safe_abi.SafeAbiFilterer, opts *bind.FilterOpts) ([]safeEvent, error) {
+			it, err := f.FilterExecutionSuccess(opts, nil)
+			if err != nil {
+				return nil, err
+			}
+
+			return drain(it, func() safeEvent { return executionSuccessEvent(it.Event) })
+		},
+		watch: func(f *safe_abi.SafeAbiFilterer, opts *bind.WatchOpts, sink chan<- safeEvent) (event.Subscription, error) {
+			return watchSafeEvent(func(logs chan<- *safe_abi.SafeAbiExecutionSuccess) (event.Subscription, error) {
+				return f.WatchExecutionSuccess(opts, logs, nil)
+			}, sink, executionSuccessEvent)
+		},
+	},
+	{
+		name: "ExecutionFailure",
+		filter: func(f *safe_abi.SafeAbiFilterer, opts *bind.FilterOpts) ([]safeEvent, error) {
+			it, err := f.FilterExecutionFailure(opts, nil)
+			if err != nil {
+				return nil, err
+			}
+
+			return drain(it, func() safeEvent { return executionFailureEvent(it.Event) })
+		},
+		watch: func(f *safe_abi.SafeAbiFilterer, opts *bind.WatchOpts, sink chan<- safeEvent) (event.Subscription, error) {
+			return watchSafeEvent(func(logs chan<- *safe_abi.SafeAbiExecutionFailure) (event.Subscription, error) {
+				return f.WatchExecutionFailure(opts, logs, nil)
+			}, sink, executionFailureEvent)
+		},
+	},
+	{
+		name: "SafeReceived",
+		filter: func(f *safe_abi.SafeAbiFilterer, opts *bind.FilterOpts) ([]safeEvent, error) {
+			it, err := f.FilterSafeReceived(opts, nil)
+			if err != nil {
+				return nil, err
+			}
+
+			return drain(it, func() safeEvent { return safeReceivedEvent(it.Event) })
+		},
+		watch: func(f *safe_abi.SafeAbiFilterer, opts *bind.WatchOpts, sink chan<- safeEvent) (event.Subscription, error) {
+			return watchSafeEvent(func(logs chan<- *safe_abi.SafeAbiSafeReceived) (event.Subscription, error) {
+				return f.WatchSafeReceived(opts, logs, nil)
+			}, sink, safeReceivedEvent)
+		},
+	},
+	{
+		name: "ApproveHash",
+		filter: func(f *safe_abi.SafeAbiFilterer, opts *bind.FilterOpts) ([]safeEvent, error) {
+			it, err := f.FilterApproveHash(opts, nil, nil)
+			if err != nil {
+				return nil, err
+			}
+
+			return drain(it, func() safeEvent { return approveHashEvent(it.Event) })
+		},
+		watch: func(f *safe_abi.SafeAbiFilterer, opts *bind.WatchOpts, sink chan<- safeEvent) (event.Subscription, error) {
+			return watchSafeEvent(func(logs chan<- *safe_abi.SafeAbiApproveHash) (event.Subscription, error) {
+				return f.WatchApproveHash(opts, logs, nil, nil)
+			}, sink, approveHashEvent)
+		},
+	},
+	{
+		name: "SignMsg",
+		filter: func(f *safe_abi.SafeAbiFilterer, opts *bind.FilterOpts) ([]safeEvent, error) {
+			it, err := f.FilterSignMsg(opts, nil)
+			if err != nil {
+				return nil, err
+			}
+
+			return drain(it, func() safeEvent { return signMsgEvent(it.Event) })
+		},
+		watch: func(f *safe_abi.SafeAbiFilterer, opts *bind.WatchOpts, sink chan<- safeEvent) (event.Subscription, error) {
+			return watchSafeEvent(func(logs chan<- *safe_abi.SafeAbiSignMsg) (event.Subscription, error) {
+				return f.WatchSignMsg(opts, logs, nil)
+			}, sink, signMsgEvent)
+		},
+	},
+	{
+		name: "SafeSetup",
+		filter: func(f *safe_abi.SafeAbiFilterer, opts *bind.FilterOpts) ([]safeEvent, error) {
+			it, err := f.FilterSafeSetup(opts, nil)
+			if err != nil {
+				return nil, err
+			}
+
+			return drain(it, func() safeEvent { return safeSetupEvent(it.Event) })
+		},
+		watch: func(f *safe_abi.SafeAbiFilterer, opts *bind.WatchOpts, sink chan<- safeEvent) (event.Subscription, error) {
+			return watchSafeEvent(func(logs chan<- *safe_abi.SafeAbiSafeSetup) (event.Subscription, error) {
+				return f.WatchSafeSetup(opts, logs, nil)
+			}, sink, safeSetupEvent)
+		},
+	},
+}
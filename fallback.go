@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func fallbackHandlerCommand() *command {
+	return &command{
+		name:        "fallback-handler",
+		args:        "",
+		description: "Inspect and manage the Safe fallback handler",
+		run:         nil,
+		subcommands: []*command{
+			fallbackHandlerGetCommand(),
+			fallbackHandlerSetCommand(),
+			fallbackHandlerUnsetCommand(),
+		},
+	}
+}
+
+func fallbackHandlerGetCommand() *command {
+	return &command{
+		name:        "get",
+		args:        "<safe>",
+		description: "Print the fallback handler address from its storage slot",
+		run: func(fs *flag.FlagSet, args []string) error {
+			positional, err := parseArgs(fs, args, 1)
+			if err != nil {
+				return err
+			}
+
+			_, caller, _, err := openSafe(positional[0])
+			if err != nil {
+				return err
+			}
+
+			handler, err := readStorageAddress(caller, fallbackHandlerSlot)
+			if err != nil {
+				return err
+			}
+
+			fmt.Println(handler.Hex())
+
+			return nil
+		},
+		subcommands: nil,
+	}
+}
+
+func fallbackHandlerSetCommand() *command {
+	return &command{
+		name:        "set",
+		args:        "<safe> <handler>",
+		description: "Build a setFallbackHandler Safe transaction",
+		run: func(fs *flag.FlagSet, args []string) error {
+			selfTx := addSelfTxFlags(fs)
+
+			positional, err := parseArgs(fs, args, 2)
+			if err != nil {
+				return err
+			}
+
+			provider, caller, safe, err := openSafe(positional[0])
+			if err != nil {
+				return err
+			}
+
+			handler, err := parseAddress(positional[1])
+			if err != nil {
+				return err
+			}
+
+			if handler == (common.Address{}) {
+				return errors.New("use fallback-handler unset to remove the fallback handler")
+			}
+
+			if handler == safe {
+				return errors.New("the fallback handler cannot be the Safe itself")
+			}
+
+			current, err := readStorageAddress(caller, fallbackHandlerSlot)
+			if err != nil {
+				return err
+			}
+
+			if current == handler {
+				return fmt.Errorf("fallback handler is already %s", handler.Hex())
+			}
+
+			err = warnNoCode(provider, "fallback handler", handler)
+			if err != nil {
+				return err
+			}
+
+			data, err := packSafeCall("setFallbackHandler", handler)
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Set fallback handler %s (current %s)\n", handler.Hex(), current.Hex())
+
+			_, err = selfTx.run(provider, caller, safe, data)
+
+			return err
+		},
+		subcommands: nil,
+	}
+}
+
+func fallbackHandlerUnsetCommand() *command {
+	return &command{
+		name:        "unset",
+		args:        "<safe>",
+		description: "Build a setFallbackHandler Safe transaction that removes the fallback handler",
+		run: func(fs *flag.FlagSet, args []string) error {
+			selfTx := addSelfTxFlags(fs)
+
+			positional, err := parseArgs(fs, args, 1)
+			if err != nil {
+				return err
+			}
+
+			provider, caller, safe, err := openSafe(positional[0])
+			if err != nil {
+				return err
+			}
+
+			current, err := readStorageAddress(caller, fallbackHandlerSlot)
+			if err != nil {
+				return err
+			}
+
+			if current == (common.Address{}) {
+				return errors.New("no fallback handler is set")
+			}
+
+			data, err := packSafeCall("setFallbackHandler", common.Address{})
+			if err != nil {
+				return err
+			}
+
+			fmt.Println("Remove fallback handler ", current.Hex())
+
+			_, err = selfTx.run(provider, caller, safe, data)
+
+			return err
+		},
+		subcommands: nil,
+	}
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/spf13/viper"
+)
+
+const (
+	defaultGasMultiplier = 1.2
+	gweiDecimals         = 9
+	etherDecimals        = 18
+)
+
+type feeFlags struct {
+	maxFee        *string
+	priorityFee   *string
+	gasLimit      *uint64
+	gasMultiplier *float64
+	legacy        *bool
+	maxSpend      *string
+}
+
+func addFeeFlags(fs *flag.FlagSet) feeFlags {
+	return feeFlags{
+		maxFee: fs.String("max-fee", viper.GetString("max_fee"),
+			"Max fee per gas in gwei, the gas price with --legacy (default 2x base fee + priority fee)"),
+		priorityFee: fs.String("priority-fee", viper.GetString("priority_fee"),
+			"Max priority fee per gas in gwei (default suggested by the node)"),
+		gasLimit: fs.Uint64("gas-limit", 0, "Gas limit (default the estimate times --gas-multiplier)"),
+		gasMultiplier: fs.Float64("gas-multiplier", defaultGasMultiplier,
+			"Multiplier applied on top of the gas estimate"),
+		legacy: fs.Bool("legacy", viper.GetBool("legacy_tx"),
+			"Send a legacy transaction with a gas price, for chains without EIP-1559"),
+		maxSpend: fs.String("max-spend", viper.GetString("max_spend"),
+			"Abort if gas limit x max fee + value exceeds this amount in ETH"),
+	}
+}
+
+// feeSettings are the parsed fee flags; nil fees are taken from the node.
+type feeSettings struct {
+	maxFee        *big.Int
+	priorityFee   *big.Int
+	gasLimit      uint64
+	gasMultiplier float64
+	legacy        bool
+	maxSpend      *big.Int
+}
+
+func parseOptionalUnits(name, value string, decimals int) (*big.Int, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil //nolint:nilnil
+	}
+
+	return parseUnits(name, value, decimals)
+}
+
+// parseUnits converts a decimal amount such as "1.5" into an integer number of 10^-decimals units.
+func parseUnits(name, value string, decimals int) (*big.Int, error) {
+	whole, fraction, _ := strings.Cut(strings.TrimSpace(value), ".")
+
+	if whole+fraction == "" || strings.Trim(whole+fraction, "0123456789") != "" {
+		return nil, fmt.Errorf("%s: invalid amount %q", name, value)
+	}
+
+	if len(fraction) > decimals {
+		return nil, fmt.Errorf("%s: %q has more than %d decimals", name, value, decimals)
+	}
+
+	amount, _ := new(big.Int).SetString(whole+fraction+strings.Repeat("0", decimals-len(fraction)), 10)
+
+	return amount, nil
+}
+
+func formatGwei(wei *big.Int) string {
+	return new(big.Float).Quo(new(big.Float).SetInt(wei), big.NewFloat(params.GWei)).Text('f', -1)
+}
+
+func (f feeFlags) settings() (feeSettings, error) {
+	maxFee, err := parseOptionalUnits("max-fee", *f.maxFee, gweiDecimals)
+	if err != nil {
+		return feeSettings{}, err
+	}
+
+	priorityFee, err := parseOptionalUnits("priority-fee", *f.priorityFee, gweiDecimals)
+	if err != nil {
+		return feeSettings{}, err
+	}
+
+	maxSpend, err := parseOptionalUnits("max-spend", *f.maxSpend, etherDecimals)
+	if err != nil {
+		return feeSettings{}, err
+	}
+
+	if *f.gasMultiplier < 1 {
+		return feeSettings{}, fmt.Errorf("--gas-multiplier must be at least 1, got %v", *f.gasMultiplier)
+	}
+
+	if *f.legacy && priorityFee != nil {
+		return feeSettings{}, fmt.Errorf("--priority-fee cannot be used with --legacy")
+	}
+
+	if maxFee != nil && priorityFee != nil && maxFee.Cmp(priorityFee) < 0 {
+		return feeSettings{}, fmt.Errorf("--max-fee %s gwei is below --priority-fee %s gwei",
+			formatGwei(maxFee), formatGwei(priorityFee))
+	}
+
+	return feeSettings{
+		maxFee:        maxFee,
+		priorityFee:   priorityFee,
+		gasLimit:      *f.gasLimit,
+		gasMultiplier: *f.gasMultiplier,
+		legacy:        *f.legacy,
+		maxSpend:      maxSpend,
+	}, nil
+}
+
+// feeQuote is the gas limit and fees a transaction is sent with: gasPrice for a legacy
+// transaction, gasFeeCap and gasTipCap otherwise.
+type feeQuote struct {
+	gasLimit  uint64
+	gasPrice  *big.Int
+	gasFeeCap *big.Int
+	gasTipCap *big.Int
+}
+
+// maxCost is the most the transaction can cost the sender: every unit of gas at the
+// highest price it may pay, plus the value sent.
+func (q feeQuote) maxCost(value *big.Int) *big.Int {
+	price := q.gasFeeCap
+	if q.gasPrice != nil {
+		price = q.gasPrice
+	}
+
+	cost := new(big.Int).Mul(price, new(big.Int).SetUint64(q.gasLimit))
+	if value != nil {
+		cost.Add(cost, value)
+	}
+
+	return cost
+}
+
+func (q feeQuote) apply(opts *bind.TransactOpts) {
+	opts.GasLimit = q.gasLimit
+	opts.GasPrice = q.gasPrice
+	opts.GasFeeCap = q.gasFeeCap
+	opts.GasTipCap = q.gasTipCap
+}
+
+func (q feeQuote) report(value *big.Int) {
+	log.Println("Gas limit: ", q.gasLimit)
+
+	if q.gasPrice != nil {
+		log.Println("Gas price (gwei): ", formatGwei(q.gasPrice))
+	} else {
+		log.Println("Max fee per gas (gwei): ", formatGwei(q.gasFeeCap))
+		log.Println("Max priority fee per gas (gwei): ", formatGwei(q.gasTipCap))
+	}
+
+	log.Println("Max cost (ETH): ", formatEther(q.maxCost(value)))
+}
+
+// quote picks the gas limit for the estimate and the fees from the flags or the node.
+// Chains whose head has no base fee get a legacy transaction.
+func (s feeSettings) quote(provider *ethclient.Client, estimate uint64) (feeQuote, error) {
+	ctx := context.Background()
+
+	gasLimit := s.gasLimit
+	if gasLimit == 0 {
+		gasLimit = uint64(math.Ceil(float64(estimate) * s.gasMultiplier))
+	}
+
+	head, err := provider.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return feeQuote{}, err
+	}
+
+	legacy := s.legacy
+	if !legacy && head.BaseFee == nil {
+		if s.priorityFee != nil {
+			return feeQuote{}, fmt.Errorf("the chain has no base fee, use --legacy instead of --priority-fee")
+		}
+
+		log.Println("The chain has no base fee, sending a legacy transaction")
+
+		legacy = true
+	}
+
+	if legacy {
+		gasPrice := s.maxFee
+		if gasPrice == nil {
+			gasPrice, err = provider.SuggestGasPrice(ctx)
+			if err != nil {
+				return feeQuote{}, err
+			}
+		}
+
+		return feeQuote{gasLimit: gasLimit, gasPrice: gasPrice, gasFeeCap: nil, gasTipCap: nil}, nil
+	}
+
+	gasTipCap := s.priorityFee
+	if gasTipCap == nil {
+		gasTipCap, err = provider.SuggestGasTipCap(ctx)
+		if err != nil {
+			return feeQuote{}, err
+		}
+	}
+
+	gasFeeCap := s.maxFee
+	if gasFeeCap == nil {
+		gasFeeCap = new(big.Int).Add(gasTipCap, new(big.Int).Mul(head.BaseFee, big.NewInt(2)))
+	}
+
+	if gasFeeCap.Cmp(gasTipCap) < 0 {
+		return feeQuote{}, fmt.Errorf("--max-fee %s gwei is below the priority fee %s gwei",
+			formatGwei(gasFeeCap), formatGwei(gasTipCap))
+	}
+
+	if gasFeeCap.Cmp(head.BaseFee) < 0 {
+		log.Printf("Warning: max fee %s gwei is below the current base fee %s gwei, the transaction will wait",
+			formatGwei(gasFeeCap), formatGwei(head.BaseFee))
+	}
+
+	return feeQuote{gasLimit: gasLimit, gasPrice: nil, gasFeeCap: gasFeeCap, gasTipCap: gasTipCap}, nil
+}
+
+func (s feeSettings) checkSpend(cost *big.Int) error {
+	return checkMaxSpend(cost, s.maxSpend)
+}
+
+// checkMaxSpend fails if cost exceeds limit; a nil limit disables the guard.
+func checkMaxSpend(cost, limit *big.Int) error {
+	if limit != nil && cost.Cmp(limit) > 0 {
+		return fmt.Errorf("max cost %s ETH exceeds --max-spend %s ETH", formatEther(cost), formatEther(limit))
+	}
+
+	return nil
+}
+
+// transact sends the transaction built by send with the fee settings applied. Unless
+// the gas limit is fixed, send first runs unsigned and unsent to obtain bind's gas
+// estimate, so the limit and the spend guard are settled before anything is signed.
+func (s feeSettings) transact(
+	provider *ethclient.Client,
+	opts *bind.TransactOpts,
+	send func(opts *bind.TransactOpts) (*types.Transaction, error),
+) (*types.Transaction, error) {
+	estimate := uint64(0)
+
+	if s.gasLimit == 0 {
+		probe := *opts
+		probe.NoSend = true
+		probe.Signer = func(_ common.Address, tx *types.Transaction) (*types.Transaction, error) {
+			return tx, nil
+		}
+
+		tx, err := send(&probe)
+		if err != nil {
+			return nil, err
+		}
+
+		estimate = tx.Gas()
+		log.Println("Gas estimate: ", estimate)
+	}
+
+	quote, err := s.quote(provider, estimate)
+	if err != nil {
+		return nil, err
+	}
+
+	quote.report(opts.Value)
+
+	err = s.checkSpend(quote.maxCost(opts.Value))
+	if err != nil {
+		return nil, err
+	}
+
+	final := *opts
+	quote.apply(&final)
+
+	return send(&final)
+}
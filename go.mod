@@ -5,6 +5,8 @@ go 1.24.0
 require (
 	github.com/ethereum/go-ethereum v1.15.1
 	github.com/spf13/viper v1.19.0
+	github.com/tyler-smith/go-bip39 v1.1.0
+	golang.org/x/term v0.28.0
 )
 
 require (
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+var (
+	// bytes4(keccak256("supportsInterface(bytes4)")).
+	erc165InterfaceID = crypto.Keccak256([]byte("supportsInterface(bytes4)"))[:4]
+	// type(Guard).interfaceId, the XOR of the Guard function selectors, see GuardManager.sol.
+	guardInterfaceID = xorSelectors(
+		"checkTransaction(address,uint256,bytes,uint8,uint256,uint256,uint256,address,address,bytes,address)",
+		"checkAfterExecution(bytes32,bool)",
+	)
+)
+
+func xorSelectors(signatures ...string) []byte {
+	id := make([]byte, 4)
+	for _, signature := range signatures {
+		selector := crypto.Keccak256([]byte(signature))[:4]
+		for i := range id {
+			id[i] ^= selector[i]
+		}
+	}
+
+	return id
+}
+
+func supportsInterface(provider *ethclient.Client, contract common.Address, interfaceID []byte) (bool, error) {
+	calldata := append(append([]byte{}, erc165InterfaceID...), common.RightPadBytes(interfaceID, 32)...)
+
+	result, err := provider.CallContract(context.Background(), ethereum.CallMsg{ //nolint:exhaustruct
+		To:   &contract,
+		Data: calldata,
+	}, nil)
+	if err != nil {
+		return false, nil //nolint:nilerr
+	}
+
+	return len(result) == 32 && result[31] == 1, nil
+}
+
+// checkGuardInterface follows the ERC-165 detection procedure for the Guard interface. Setting a
+// guard that does not implement it makes every execTransaction revert and bricks the Safe.
+func checkGuardInterface(provider *ethclient.Client, guard common.Address) error {
+	code, err := provider.CodeAt(context.Background(), guard, nil)
+	if err != nil {
+		return err
+	}
+
+	if len(code) == 0 {
+		return fmt.Errorf("guard %s has no contract code", guard.Hex())
+	}
+
+	checks := []struct {
+		interfaceID []byte
+		expected    bool
+	}{
+		{erc165InterfaceID, true},
+		{[]byte{0xff, 0xff, 0xff, 0xff}, false},
+		{guardInterfaceID, true},
+	}
+	for _, check := range checks {
+		supported, err := supportsInterface(provider, guard, check.interfaceID)
+		if err != nil {
+			return err
+		}
+
+		if supported != check.expected {
+			return fmt.Errorf(
+				"guard %s does not implement the Guard interface %#x via ERC-165",
+				guard.Hex(),
+				guardInterfaceID,
+			)
+		}
+	}
+
+	return nil
+}
+
+func guardCommand() *command {
+	return &command{
+		name:        "guard",
+		args:        "",
+		description: "Inspect and manage the Safe transaction guard",
+		run:         nil,
+		subcommands: []*command{
+			guardGetCommand(),
+			guardSetCommand(),
+			guardUnsetCommand(),
+		},
+	}
+}
+
+func guardGetCommand() *command {
+	return &command{
+		name:        "get",
+		args:        "<safe>",
+		description: "Print the guard address from its storage slot",
+		run: func(fs *flag.FlagSet, args []string) error {
+			positional, err := parseArgs(fs, args, 1)
+			if err != nil {
+				return err
+			}
+
+			_, caller, _, err := openSafe(positional[0])
+			if err != nil {
+				return err
+			}
+
+			guard, err := readStorageAddress(caller, guardSlot)
+			if err != nil {
+				return err
+			}
+
+			fmt.Println(guard.Hex())
+
+			return nil
+		},
+		subcommands: nil,
+	}
+}
+
+func guardSetCommand() *command {
+	return &command{
+		name:        "set",
+		args:        "<safe> <guard>",
+		description: "Build a setGuard Safe transaction after checking the guard supports ERC-165",
+		run: func(fs *flag.FlagSet, args []string) error {
+			selfTx := addSelfTxFlags(fs)
+
+			positional, err := parseArgs(fs, args, 2)
+			if err != nil {
+				return err
+			}
+
+			provider, caller, safe, err := openSafe(positional[0])
+			if err != nil {
+				return err
+			}
+
+			guard, err := parseAddress(positional[1])
+			if err != nil {
+				return err
+			}
+
+			if guard == (common.Address{}) {
+				return errors.New("use guard unset to remove the guard")
+			}
+
+			current, err := readStorageAddress(caller, guardSlot)
+			if err != nil {
+				return err
+			}
+
+			if current == guard {
+				return fmt.Errorf("guard is already %s", guard.Hex())
+			}
+
+			err = checkGuardInterface(provider, guard)
+			if err != nil {
+				return err
+			}
+
+			data, err := packSafeCall("setGuard", guard)
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Set guard %s (current %s)\n", guard.Hex(), current.Hex())
+
+			_, err = selfTx.run(provider, caller, safe, data)
+
+			return err
+		},
+		subcommands: nil,
+	}
+}
+
+func guardUnsetCommand() *command {
+	return &command{
+		name:        "unset",
+		args:        "<safe>",
+		description: "Build a setGuard Safe transaction that removes the guard",
+		run: func(fs *flag.FlagSet, args []string) error {
+			selfTx := addSelfTxFlags(fs)
+
+			positional, err := parseArgs(fs, args, 1)
+			if err != nil {
+				return err
+			}
+
+			provider, caller, safe, err := openSafe(positional[0])
+			if err != nil {
+				return err
+			}
+
+			current, err := readStorageAddress(caller, guardSlot)
+			if err != nil {
+				return err
+			}
+
+			if current == (common.Address{}) {
+				return errors.New("no guard is set")
+			}
+
+			data, err := packSafeCall("setGuard", common.Address{})
+			if err != nil {
+				return err
+			}
+
+			fmt.Println("Remove guard ", current.Hex())
+
+			_, err = selfTx.run(provider, caller, safe, data)
+
+			return err
+		},
+		subcommands: nil,
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const hardenedKeyStart = 0x80000000
+
+var errInvalidHDKey = errors.New("derived key is invalid, use another index")
+
+// deriveHDKey derives the private key at path from a BIP-39 seed as specified by BIP-32.
+func deriveHDKey(seed []byte, path accounts.DerivationPath) (*ecdsa.PrivateKey, error) {
+	mac := hmac.New(sha512.New, []byte("Bitcoin seed"))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+
+	n := crypto.S256().Params().N
+
+	key := new(big.Int).SetBytes(sum[:32])
+	chainCode := sum[32:]
+
+	if key.Sign() == 0 || key.Cmp(n) >= 0 {
+		return nil, errInvalidHDKey
+	}
+
+	for _, index := range path {
+		var data []byte
+
+		if index >= hardenedKeyStart {
+			data = append([]byte{0}, math.PaddedBigBytes(key, 32)...)
+		} else {
+			parent, err := crypto.ToECDSA(math.PaddedBigBytes(key, 32))
+			if err != nil {
+				return nil, err
+			}
+
+			data = crypto.CompressPubkey(&parent.PublicKey)
+		}
+
+		data = binary.BigEndian.AppendUint32(data, index)
+
+		mac = hmac.New(sha512.New, chainCode)
+		mac.Write(data)
+		sum = mac.Sum(nil)
+
+		tweak := new(big.Int).SetBytes(sum[:32])
+		if tweak.Cmp(n) >= 0 {
+			return nil, errInvalidHDKey
+		}
+
+		key = tweak.Add(tweak, key).Mod(tweak, n)
+		if key.Sign() == 0 {
+			return nil, errInvalidHDKey
+		}
+
+		chainCode = sum[32:]
+	}
+
+	return crypto.ToECDSA(math.PaddedBigBytes(key, 32))
+}
@@ -0,0 +1,488 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/timofvy/multisig/abi/safe_abi"
+	"github.com/timofvy/multisig/abi/safe_proxy_factory_abi"
+)
+
+const defaultRegistryPath = "safes.json"
+
+// registryEntry is an indexed Safe. Uninitialized marks a proxy whose setup was never
+// called, it has no owners and threshold 0 until someone initializes it. Error holds why
+// owners and threshold could not be read, e.g. the proxy of a singleton that is not a Safe.
+type registryEntry struct {
+	Address       string   `json:"address"`
+	Singleton     string   `json:"singleton"`
+	BlockNumber   uint64   `json:"blockNumber"`
+	TxHash        string   `json:"txHash"`
+	Owners        []string `json:"owners"`
+	Threshold     string   `json:"threshold"`
+	Uninitialized bool     `json:"uninitialized,omitempty"`
+	Error         string   `json:"error,omitempty"`
+}
+
+// readEntryOwners fills owners and threshold of the entry. A failed read is recorded on the
+// entry rather than returned, so one proxy cannot stop the scan; --refresh reads it again.
+func (x *factoryIndexer) readEntryOwners(entry *registryEntry) {
+	owners, threshold, err := readSafeOwners(x.provider, common.HexToAddress(entry.Address))
+	if err != nil {
+		log.Printf("Warning: cannot read owners of %s: %v", entry.Address, err)
+
+		entry.Owners = []string{}
+		entry.Threshold = ""
+		entry.Uninitialized = false
+		entry.Error = err.Error()
+
+		return
+	}
+
+	entry.Owners = owners
+	entry.Threshold = threshold
+	entry.Uninitialized = len(owners) == 0
+	entry.Error = ""
+}
+
+// safeRegistry is the local index of Safes created by a factory. An empty singleton
+// means proxies of every singleton are indexed.
+type safeRegistry struct {
+	ChainID   string          `json:"chainId"`
+	Factory   string          `json:"factory"`
+	Singleton string          `json:"singleton,omitempty"`
+	LastBlock uint64          `json:"lastBlock"`
+	Safes     []registryEntry `json:"safes"`
+}
+
+// readRegistry loads the registry from path, or starts an empty one if the file does
+// not exist. An existing registry must belong to the same chain, factory and singleton.
+func readRegistry(path string, chainID string, factory, singleton common.Address) (safeRegistry, error) {
+	expected := safeRegistry{
+		ChainID:   chainID,
+		Factory:   factory.Hex(),
+		Singleton: "",
+		LastBlock: 0,
+		Safes:     []registryEntry{},
+	}
+
+	if singleton != (common.Address{}) {
+		expected.Singleton = singleton.Hex()
+	}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return expected, nil
+	}
+
+	if err != nil {
+		return safeRegistry{}, err
+	}
+
+	var registry safeRegistry
+
+	err = json.Unmarshal(content, &registry)
+	if err != nil {
+		return safeRegistry{}, fmt.Errorf("invalid registry %s: %w", path, err)
+	}
+
+	if registry.ChainID != expected.ChainID || registry.Factory != expected.Factory ||
+		registry.Singleton != expected.Singleton {
+		return safeRegistry{}, fmt.Errorf(
+			"registry %s indexes factory %s (singleton %q) on chain %s, not factory %s (singleton %q) on chain %s",
+			path, registry.Factory, registry.Singleton, registry.ChainID,
+			expected.Factory, expected.Singleton, expected.ChainID,
+		)
+	}
+
+	return registry, nil
+}
+
+// writeRegistry replaces the registry file atomically so a crash never leaves it half written.
+func writeRegistry(path string, registry safeRegistry) error {
+	content, err := json.MarshalIndent(registry, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+
+	err = os.WriteFile(tmp, append(content, '\n'), 0o644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+type factoryIndexer struct {
+	provider  *ethclient.Client
+	filterer  *safe_proxy_factory_abi.SafeProxyFactoryAbiFilterer
+	singleton common.Address
+	registry  safeRegistry
+	path      string
+}
+
+func (x *factoryIndexer) find(proxy common.Address) int {
+	for i, entry := range x.registry.Safes {
+		if entry.Address == proxy.Hex() {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// readSafeOwners reads owners and threshold of the Safe. A proxy that was created without
+// an initializer has threshold 0 and getOwners reverts, so its owners are left empty.
+func readSafeOwners(provider *ethclient.Client, safe common.Address) ([]string, string, error) {
+	caller, err := safe_abi.NewSafeAbiCaller(safe, provider)
+	if err != nil {
+		return nil, "", err
+	}
+
+	opts := &bind.CallOpts{Context: context.Background()} //nolint:exhaustruct
+
+	threshold, err := caller.GetThreshold(opts)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if threshold.Sign() == 0 {
+		return []string{}, threshold.String(), nil
+	}
+
+	owners, err := caller.GetOwners(opts)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return hexAddresses(owners), threshold.String(), nil
+}
+
+// apply adds a ProxyCreation event to the registry, or drops the proxy again if the
+// log was removed by a reorg. Proxies of other singletons are ignored.
+func (x *factoryIndexer) apply(creation *safe_proxy_factory_abi.SafeProxyFactoryAbiProxyCreation) error {
+	if x.singleton != (common.Address{}) && creation.Singleton != x.singleton {
+		return nil
+	}
+
+	index := x.find(creation.Proxy)
+
+	if creation.Raw.Removed {
+		if index >= 0 {
+			log.Printf("Safe %s removed by a reorg", creation.Proxy.Hex())
+
+			x.registry.Safes = append(x.registry.Safes[:index], x.registry.Safes[index+1:]...)
+		}
+
+		return nil
+	}
+
+	if index >= 0 {
+		return nil
+	}
+
+	entry := registryEntry{ //nolint:exhaustruct
+		Address:     creation.Proxy.Hex(),
+		Singleton:   creation.Singleton.Hex(),
+		BlockNumber: creation.Raw.BlockNumber,
+		TxHash:      creation.Raw.TxHash.Hex(),
+	}
+
+	x.readEntryOwners(&entry)
+	x.registry.Safes = append(x.registry.Safes, entry)
+
+	switch {
+	case entry.Error != "":
+	case entry.Uninitialized:
+		log.Printf("Warning: Safe %s created in block %d is not initialized", entry.Address, entry.BlockNumber)
+	default:
+		log.Printf("Safe %s (%s of %d) created in block %d", entry.Address, entry.Threshold, len(entry.Owners),
+			entry.BlockNumber)
+	}
+
+	return nil
+}
+
+func (x *factoryIndexer) filter(start, end uint64) error {
+	it, err := x.filterer.FilterProxyCreation(
+		&bind.FilterOpts{Start: start, End: &end, Context: context.Background()},
+		nil,
+	)
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+
+	for it.Next() {
+		err = x.apply(it.Event)
+		if err != nil {
+			return err
+		}
+	}
+
+	return it.Error()
+}
+
+// scan indexes [from, to] in chunks, saving the registry after every chunk so an
+// interrupted scan resumes where it stopped.
+func (x *factoryIndexer) scan(from, to, chunk uint64) error {
+	return scanChunks(from, to, chunk, func(start, end uint64) error {
+		err := x.filter(start, end)
+		if err != nil {
+			return err
+		}
+
+		x.registry.LastBlock = end
+
+		log.Printf("Indexed blocks %d-%d: %d safes", start, end, len(x.registry.Safes))
+
+		return writeRegistry(x.path, x.registry)
+	})
+}
+
+// refresh re-reads owners and threshold of every indexed Safe.
+func (x *factoryIndexer) refresh() error {
+	for i := range x.registry.Safes {
+		x.readEntryOwners(&x.registry.Safes[i])
+	}
+
+	return writeRegistry(x.path, x.registry)
+}
+
+// poll indexes new blocks every interval.
+func (x *factoryIndexer) poll(ctx context.Context, chunk uint64, interval time.Duration) error {
+	for {
+		latest, err := x.provider.BlockNumber(ctx)
+		if err == nil && latest > x.registry.LastBlock {
+			err = x.scan(x.registry.LastBlock+1, latest, chunk)
+		}
+
+		if err != nil {
+			log.Println("Poll failed:", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// subscribe follows the factory through WatchProxyCreation. Whenever the subscription
+// is (re-)established, blocks since the last indexed one are re-read with
+// FilterProxyCreation so nothing mined in between is missed.
+func (x *factoryIndexer) subscribe(ctx context.Context) error {
+	sink := make(chan *safe_proxy_factory_abi.SafeProxyFactoryAbiProxyCreation)
+
+	var last atomic.Uint64
+	last.Store(x.registry.LastBlock)
+
+	sub := event.ResubscribeErr(resubscribeBackoff, func(ctx context.Context, lastErr error) (event.Subscription, error) {
+		if lastErr != nil {
+			log.Println("ProxyCreation subscription dropped:", lastErr)
+		}
+
+		sub, err := x.filterer.WatchProxyCreation(&bind.WatchOpts{Start: nil, Context: ctx}, sink, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		it, err := x.filterer.FilterProxyCreation(
+			&bind.FilterOpts{Start: last.Load(), End: nil, Context: ctx},
+			nil,
+		)
+		if err != nil {
+			sub.Unsubscribe()
+
+			return nil, err
+		}
+		defer it.Close()
+
+		for it.Next() {
+			select {
+			case sink <- it.Event:
+			case <-ctx.Done():
+				sub.Unsubscribe()
+
+				return nil, ctx.Err()
+			}
+		}
+
+		return sub, it.Error()
+	})
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case creation := <-sink:
+			err := x.apply(creation)
+			if err != nil {
+				log.Println(err)
+
+				continue
+			}
+
+			x.registry.LastBlock = max(x.registry.LastBlock, creation.Raw.BlockNumber)
+			last.Store(x.registry.LastBlock)
+
+			err = writeRegistry(x.path, x.registry)
+			if err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func indexCommand() *command {
+	return &command{
+		name:        "index",
+		args:        "",
+		description: "Index Safes created by the proxy factory into a local registry",
+		run: func(fs *flag.FlagSet, args []string) error {
+			fs.String("factory", "", "Proxy factory address (default safe_proxy_factory)")
+			fs.String("singleton", "", "Index only proxies of this singleton, empty for all (default safe)")
+			path := fs.String("registry", defaultRegistryPath, "Registry file")
+			from := fs.Uint64("from", 0, "First block to scan (default the block after the last indexed one)")
+			to := fs.Uint64("to", 0, "Last block to scan (default latest)")
+			chunk := fs.Uint64("chunk", defaultAuditChunk, "Blocks per eth_getLogs request")
+			refresh := fs.Bool("refresh", false, "Re-read owners and threshold of already indexed Safes")
+			follow := fs.Bool("follow", false, "Keep running and index new Safes as they are created")
+			poll := fs.Bool("poll", false, "Poll with eth_getLogs even if the RPC supports subscriptions")
+			interval := fs.Duration("interval", defaultPollInterval, "Polling interval")
+
+			_, err := parseArgs(fs, args, 0)
+			if err != nil {
+				return err
+			}
+
+			err = applyDeploymentRegistry()
+			if err != nil {
+				return err
+			}
+
+			if *chunk == 0 {
+				return fmt.Errorf("--chunk must be positive")
+			}
+
+			factory, err := parseAddress(flagOrConfig(fs, "factory", "safe_proxy_factory"))
+			if err != nil {
+				return fmt.Errorf("factory: %w", err)
+			}
+
+			singleton, err := parseOptionalAddress("singleton", flagOrConfig(fs, "singleton", "safe"))
+			if err != nil {
+				return err
+			}
+
+			err = checkFactoryEventVersion(factory)
+			if err != nil {
+				return err
+			}
+
+			provider, err := getProvider()
+			if err != nil {
+				return err
+			}
+
+			chainID, err := provider.ChainID(context.Background())
+			if err != nil {
+				return err
+			}
+
+			registry, err := readRegistry(*path, chainID.String(), factory, singleton)
+			if err != nil {
+				return err
+			}
+
+			filterer, err := safe_proxy_factory_abi.NewSafeProxyFactoryAbiFilterer(factory, provider)
+			if err != nil {
+				return err
+			}
+
+			indexer := &factoryIndexer{
+				provider:  provider,
+				filterer:  filterer,
+				singleton: singleton,
+				registry:  registry,
+				path:      *path,
+			}
+
+			if *refresh {
+				err = indexer.refresh()
+				if err != nil {
+					return err
+				}
+			}
+
+			start := *from
+			if start == 0 && registry.LastBlock > 0 {
+				start = registry.LastBlock + 1
+			}
+
+			last := *to
+			if last == 0 {
+				last, err = provider.BlockNumber(context.Background())
+				if err != nil {
+					return err
+				}
+			}
+
+			if start > last && *from != 0 {
+				return errInvalidBlockRange
+			}
+
+			if start <= last {
+				err = indexer.scan(start, last, *chunk)
+				if err != nil {
+					return err
+				}
+			}
+
+			log.Printf("Registry %s: %d safes indexed up to block %d", *path, len(indexer.registry.Safes), indexer.registry.LastBlock)
+
+			if !*follow {
+				return nil
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			subscriptions := false
+			if !*poll {
+				subscriptions, err = supportsSubscriptions(ctx, provider)
+				if err != nil {
+					return err
+				}
+			}
+
+			if subscriptions {
+				log.Println("Following the factory via subscriptions")
+
+				return indexer.subscribe(ctx)
+			}
+
+			log.Printf("Following the factory by polling every %s", *interval)
+
+			return indexer.poll(ctx, *chunk, *interval)
+		},
+		subcommands: nil,
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/timofvy/multisig/abi/safe_abi"
+)
+
+type safeInfo struct {
+	Address         string   `json:"address"`
+	ChainID         string   `json:"chainId"`
+	Version         string   `json:"version"`
+	Nonce           string   `json:"nonce"`
+	Threshold       string   `json:"threshold"`
+	Owners          []string `json:"owners"`
+	Modules         []string `json:"modules"`
+	Guard           string   `json:"guard"`
+	FallbackHandler string   `json:"fallbackHandler"`
+	Singleton       string   `json:"singleton"`
+	Balance         string   `json:"balance"`
+}
+
+func hexAddresses(addresses []common.Address) []string {
+	result := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		result = append(result, address.Hex())
+	}
+
+	return result
+}
+
+func getSafeInfo(provider *ethclient.Client, caller *safe_abi.SafeAbiCaller, safe common.Address) (safeInfo, error) {
+	opts := &bind.CallOpts{Context: context.Background()} //nolint:exhaustruct
+
+	chainID, err := provider.ChainID(context.Background())
+	if err != nil {
+		return safeInfo{}, err
+	}
+
+	version, err := caller.VERSION(opts)
+	if err != nil {
+		return safeInfo{}, err
+	}
+
+	nonce, err := caller.Nonce(opts)
+	if err != nil {
+		return safeInfo{}, err
+	}
+
+	threshold, err := caller.GetThreshold(opts)
+	if err != nil {
+		return safeInfo{}, err
+	}
+
+	owners, err := caller.GetOwners(opts)
+	if err != nil {
+		return safeInfo{}, err
+	}
+
+	modules, err := getModules(caller)
+	if err != nil {
+		return safeInfo{}, err
+	}
+
+	guard, err := readStorageAddress(caller, guardSlot)
+	if err != nil {
+		return safeInfo{}, err
+	}
+
+	fallbackHandler, err := readStorageAddress(caller, fallbackHandlerSlot)
+	if err != nil {
+		return safeInfo{}, err
+	}
+
+	singleton, err := readStorageAddress(caller, common.Hash{})
+	if err != nil {
+		return safeInfo{}, err
+	}
+
+	balance, err := provider.BalanceAt(context.Background(), safe, nil)
+	if err != nil {
+		return safeInfo{}, err
+	}
+
+	return safeInfo{
+		Address:         safe.Hex(),
+		ChainID:         chainID.String(),
+		Version:         version,
+		Nonce:           nonce.String(),
+		Threshold:       threshold.String(),
+		Owners:          hexAddresses(owners),
+		Modules:         hexAddresses(modules),
+		Guard:           guard.Hex(),
+		FallbackHandler: fallbackHandler.Hex(),
+		Singleton:       singleton.Hex(),
+		Balance:         balance.String(),
+	}, nil
+}
+
+func printSafeInfo(info safeInfo) error {
+	balance, ok := new(big.Int).SetString(info.Balance, 10)
+	if !ok {
+		return fmt.Errorf("invalid balance %q", info.Balance)
+	}
+
+	fmt.Println("Safe:             ", info.Address)
+	fmt.Println("Chain ID:         ", info.ChainID)
+	fmt.Println("Version:          ", info.Version)
+	fmt.Println("Singleton:        ", info.Singleton)
+	fmt.Println("Nonce:            ", info.Nonce)
+	fmt.Printf("Threshold:         %s of %d\n", info.Threshold, len(info.Owners))
+	fmt.Println("Balance (ETH):    ", formatEther(balance))
+	fmt.Println("Guard:            ", info.Guard)
+	fmt.Println("Fallback handler: ", info.FallbackHandler)
+	fmt.Println("Owners:")
+
+	for _, owner := range info.Owners {
+		fmt.Println("  ", owner)
+	}
+
+	fmt.Println("Modules:")
+
+	if len(info.Modules) == 0 {
+		fmt.Println("   none")
+	}
+
+	for _, module := range info.Modules {
+		fmt.Println("  ", module)
+	}
+
+	return nil
+}
+
+func infoCommand() *command {
+	return &command{
+		name:        "info",
+		args:        "<safe>",
+		description: "Show the on-chain state of a Safe",
+		run: func(fs *flag.FlagSet, args []string) error {
+			jsonOutput := fs.Bool("json", false, "Print the state as JSON")
+
+			positional, err := parseArgs(fs, args, 1)
+			if err != nil {
+				return err
+			}
+
+			provider, caller, safe, err := openSafe(positional[0])
+			if err != nil {
+				return err
+			}
+
+			info, err := getSafeInfo(provider, caller, safe)
+			if err != nil {
+				return err
+			}
+
+			if *jsonOutput {
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+
+				return encoder.Encode(info)
+			}
+
+			return printSafeInfo(info)
+		},
+		subcommands: nil,
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+func modulesCommand() *command {
+	return &command{
+		name:        "modules",
+		args:        "",
+		description: "Inspect and manage Safe modules",
+		run:         nil,
+		subcommands: []*command{
+			modulesListCommand(),
+			modulesEnableCommand(),
+			modulesDisableCommand(),
+		},
+	}
+}
+
+func modulesListCommand() *command {
+	return &command{
+		name:        "list",
+		args:        "<safe>",
+		description: "List all enabled modules",
+		run: func(fs *flag.FlagSet, args []string) error {
+			positional, err := parseArgs(fs, args, 1)
+			if err != nil {
+				return err
+			}
+
+			_, caller, _, err := openSafe(positional[0])
+			if err != nil {
+				return err
+			}
+
+			modules, err := getModules(caller)
+			if err != nil {
+				return err
+			}
+
+			for _, module := range modules {
+				fmt.Println(module.Hex())
+			}
+
+			return nil
+		},
+		subcommands: nil,
+	}
+}
+
+// prevModule returns the module pointing to module in the ModuleManager linked list.
+func prevModule(modules []common.Address, module common.Address) (common.Address, error) {
+	for i, current := range modules {
+		if current != module {
+			continue
+		}
+
+		if i == 0 {
+			return common.HexToAddress(sentinelAddress), nil
+		}
+
+		return modules[i-1], nil
+	}
+
+	return common.Address{}, fmt.Errorf("module %s is not enabled", module.Hex())
+}
+
+func warnNoCode(provider *ethclient.Client, name string, address common.Address) error {
+	code, err := provider.CodeAt(context.Background(), address, nil)
+	if err != nil {
+		return err
+	}
+
+	if len(code) == 0 {
+		log.Printf("Warning: %s %s has no contract code", name, address.Hex())
+	}
+
+	return nil
+}
+
+func modulesEnableCommand() *command {
+	return &command{
+		name:        "enable",
+		args:        "<safe> <module>",
+		description: "Build an enableModule Safe transaction",
+		run: func(fs *flag.FlagSet, args []string) error {
+			selfTx := addSelfTxFlags(fs)
+
+			positional, err := parseArgs(fs, args, 2)
+			if err != nil {
+				return err
+			}
+
+			provider, caller, safe, err := openSafe(positional[0])
+			if err != nil {
+				return err
+			}
+
+			module, err := parseAddress(positional[1])
+			if err != nil {
+				return err
+			}
+
+			if module == common.HexToAddress(zeroAddress) || module == common.HexToAddress(sentinelAddress) {
+				return fmt.Errorf("invalid module address %s", module.Hex())
+			}
+
+			enabled, err := caller.IsModuleEnabled(&bind.CallOpts{Context: context.Background()}, module) //nolint:exhaustruct
+			if err != nil {
+				return err
+			}
+
+			if enabled {
+				return fmt.Errorf("module %s is already enabled", module.Hex())
+			}
+
+			err = warnNoCode(provider, "module", module)
+			if err != nil {
+				return err
+			}
+
+			data, err := packSafeCall("enableModule", module)
+			if err != nil {
+				return err
+			}
+
+			fmt.Println("Enable module ", module.Hex())
+
+			_, err = selfTx.run(provider, caller, safe, data)
+
+			return err
+		},
+		subcommands: nil,
+	}
+}
+
+func modulesDisableCommand() *command {
+	return &command{
+		name:        "disable",
+		args:        "<safe> <module>",
+		description: "Build a disableModule Safe transaction",
+		run: func(fs *flag.FlagSet, args []string) error {
+			selfTx := addSelfTxFlags(fs)
+
+			positional, err := parseArgs(fs, args, 2)
+			if err != nil {
+				return err
+			}
+
+			provider, caller, safe, err := openSafe(positional[0])
+			if err != nil {
+				return err
+			}
+
+			module, err := parseAddress(positional[1])
+			if err != nil {
+				return err
+			}
+
+			modules, err := getModules(caller)
+			if err != nil {
+				return err
+			}
+
+			prev, err := prevModule(modules, module)
+			if err != nil {
+				return err
+			}
+
+			data, err := packSafeCall("disableModule", prev, module)
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Disable module %s (prev %s)\n", module.Hex(), prev.Hex())
+
+			_, err = selfTx.run(provider, caller, safe, data)
+
+			return err
+		},
+		subcommands: nil,
+	}
+}
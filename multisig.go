@@ -2,23 +2,15 @@ package main
 
 import (
 	"context"
-	"errors"
 	"flag"
+	"fmt"
 	"log"
-	"math/big"
 	"net/http"
-	"strings"
 	"time"
 
-	"github.com/ethereum/go-ethereum/accounts/abi"
-	"github.com/ethereum/go-ethereum/accounts/abi/bind"
-	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/spf13/viper"
-	"github.com/timofvy/multisig/abi/safe_abi"
-	"github.com/timofvy/multisig/abi/safe_proxy_factory_abi"
 )
 
 const zeroAddress = "0x0000000000000000000000000000000000000000"
@@ -43,110 +35,33 @@ func getProvider() (*ethclient.Client, error) {
 
 	connection := ethclient.NewClient(rpcClient)
 
-	return connection, nil
-}
-
-func main() {
-	LoadConfig()
-
-	owners := flag.String("owners", "", "Owners")
-	threshold := flag.Int("threshold", 0, "Threshold")
-	flag.Parse()
-
-	signerAddrs := strings.Split(*owners, ",")
-
-	var ownersAddrSlice []common.Address
-	for _, addr := range signerAddrs {
-		trimmedAddr := strings.TrimSpace(addr)
-		ownerAddr := common.HexToAddress(trimmedAddr)
-
-		ownersAddrSlice = append(ownersAddrSlice, ownerAddr)
-	}
-
-	err := sendDeployMultisig(ownersAddrSlice, *threshold)
-	if err != nil {
-		log.Println(err)
-		return
-	}
-}
-
-func sendDeployMultisig(
-	owners []common.Address,
-	threshold int,
-) error {
-	if threshold == 0 {
-		return errors.New("threshold must be greater than 0")
-	}
-
-	if len(owners) == 0 {
-		return errors.New("owners must be greater than 0")
-	}
-
-	priv := viper.GetString("private_key")
-	safeProxyFactoryAddress := common.HexToAddress(viper.GetString("safe_proxy_factory"))
-	safeAddress := common.HexToAddress(viper.GetString("safe"))
-
-	contractAbi, err := abi.JSON(strings.NewReader(safe_abi.SafeAbiABI))
-	if err != nil {
-		return err
-	}
-
-	data, err := contractAbi.Pack("setup",
-		owners,
-		big.NewInt(int64(threshold)),
-		common.HexToAddress(zeroAddress),
-		[]byte{0},
-		common.HexToAddress(zeroAddress),
-		common.HexToAddress(zeroAddress),
-		big.NewInt(0),
-		common.HexToAddress(zeroAddress),
-	)
-	if err != nil {
-		return err
+	expectedChainID := viper.GetUint64("chain_id")
+	if expectedChainID == 0 {
+		return connection, nil
 	}
 
-	provider, err := getProvider()
+	chainID, err := connection.ChainID(context.Background())
 	if err != nil {
-		return err
+		return &ethclient.Client{}, err
 	}
 
-	contractTransactor, err := safe_proxy_factory_abi.NewSafeProxyFactoryAbiTransactor(
-		safeProxyFactoryAddress,
-		provider,
-	)
-	if err != nil {
-		return err
+	if chainID.Uint64() != expectedChainID {
+		return &ethclient.Client{}, fmt.Errorf("rpc is connected to chain %s, expected %d", chainID.String(), expectedChainID)
 	}
 
-	chainID, err := provider.ChainID(context.Background())
-	if err != nil {
-		return err
-	}
+	return connection, nil
+}
 
-	privateKey, err := crypto.HexToECDSA(priv)
-	if err != nil {
-		return err
-	}
+func main() {
+	LoadConfig()
 
-	trOpts, err := bind.NewKeyedTransactorWithChainID(
-		privateKey,
-		chainID,
-	)
+	err := parseGlobalFlags()
 	if err != nil {
-		return err
+		log.Fatalln(err)
 	}
 
-	transaction, err := contractTransactor.CreateProxyWithNonce(
-		trOpts,
-		safeAddress,
-		data,
-		big.NewInt(0),
-	)
+	err = runCommand("multisig", commands(), flag.Args())
 	if err != nil {
-		return err
+		log.Fatalln(err)
 	}
-
-	log.Println("Transaction sent: ", transaction.Hash().Hex())
-
-	return nil
 }
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/spf13/viper"
+)
+
+const (
+	defaultNetworksPath = "networks.yaml"
+	defaultSafeVersion  = "1.4.1"
+
+	chainDetectTimeout = 5 * time.Second
+)
+
+// deploymentsJSON is the registry of official Safe deployments by chain ID and version.
+//
+//go:embed deployments.json
+var deploymentsJSON []byte
+
+// safeDeployment holds the addresses of one Safe release on one chain.
+type safeDeployment struct {
+	Safe             string `json:"safe"`
+	SafeL2           string `json:"safeL2"`
+	SafeProxyFactory string `json:"safeProxyFactory"`
+	MultiSend        string `json:"multiSend"`
+	FallbackHandler  string `json:"fallbackHandler"`
+	SignMessageLib   string `json:"signMessageLib"`
+}
+
+type chainDeployments struct {
+	Name     string                    `json:"name"`
+	Versions map[string]safeDeployment `json:"versions"`
+}
+
+// networkKeys are the settings a network profile may set, the same keys as in .env.
+var networkKeys = []string{
+	"rpc_url",
+	"chain_id",
+	"safe_version",
+	"safe_l2",
+	"safe",
+	"safe_proxy_factory",
+	"fallback_handler",
+	"multi_send",
+	"sign_message_lib",
+}
+
+// safeVersions are the Safe releases the registry knows.
+var safeVersions = []string{"1.3.0", "1.4.1"}
+
+// deploymentKeys are the contract addresses the registry fills in.
+var deploymentKeys = []string{"safe", "safe_proxy_factory", "fallback_handler", "multi_send", "sign_message_lib"}
+
+// addressSources records where each contract address came from, for networks show.
+var addressSources = map[string]string{}
+
+func loadDeployments() (map[string]chainDeployments, error) {
+	var deployments map[string]chainDeployments
+
+	err := json.Unmarshal(deploymentsJSON, &deployments)
+	if err != nil {
+		return nil, fmt.Errorf("invalid deployment registry: %w", err)
+	}
+
+	return deployments, nil
+}
+
+// deploymentFor returns the official deployment of version on the chain, if any.
+func deploymentFor(chainID uint64, version string) (safeDeployment, bool, error) {
+	deployments, err := loadDeployments()
+	if err != nil {
+		return safeDeployment{}, false, err
+	}
+
+	deployment, ok := deployments[strconv.FormatUint(chainID, 10)].Versions[version]
+
+	return deployment, ok, nil
+}
+
+func (d safeDeployment) addresses(l2 bool) map[string]string {
+	singleton := d.Safe
+	if l2 {
+		singleton = d.SafeL2
+	}
+
+	return map[string]string{
+		"safe":               singleton,
+		"safe_proxy_factory": d.SafeProxyFactory,
+		"fallback_handler":   d.FallbackHandler,
+		"multi_send":         d.MultiSend,
+		"sign_message_lib":   d.SignMessageLib,
+	}
+}
+
+func readNetworks() (*viper.Viper, error) {
+	path := viper.GetString("networks_file")
+	if path == "" {
+		path = defaultNetworksPath
+	}
+
+	networks := viper.New()
+	networks.SetConfigFile(path)
+	networks.SetConfigType("yaml")
+
+	err := networks.ReadInConfig()
+	if err != nil {
+		return nil, fmt.Errorf("network profiles %s: %w", path, err)
+	}
+
+	return networks, nil
+}
+
+// applyNetworkProfile sets the values of the named profile on top of .env. Values may
+// reference environment variables as ${VAR}, e.g. an API key in the RPC URL.
+func applyNetworkProfile(name string) error {
+	networks, err := readNetworks()
+	if err != nil {
+		return err
+	}
+
+	profile := networks.Sub(name)
+	if profile == nil {
+		return fmt.Errorf("unknown network %q, profiles: %s", name, strings.Join(profileNames(networks), ", "))
+	}
+
+	for _, key := range profile.AllKeys() {
+		if !slices.Contains(networkKeys, key) {
+			return fmt.Errorf("network %s: unknown setting %q", name, key)
+		}
+
+		value := profile.Get(key)
+		if text, ok := value.(string); ok {
+			value = os.ExpandEnv(text)
+		}
+
+		viper.Set(key, value)
+
+		if slices.Contains(deploymentKeys, key) {
+			addressSources[key] = "network " + name
+		}
+	}
+
+	return nil
+}
+
+func profileNames(networks *viper.Viper) []string {
+	var names []string
+
+	for key := range networks.AllSettings() {
+		names = append(names, key)
+	}
+
+	slices.Sort(names)
+
+	return names
+}
+
+// safeVersion returns the configured Safe release, one of those in the registry.
+func safeVersion() (string, error) {
+	version := viper.GetString("safe_version")
+	if version == "" {
+		return defaultSafeVersion, nil
+	}
+
+	if !slices.Contains(safeVersions, version) {
+		return "", fmt.Errorf("unsupported safe_version %q, expected one of %s", version, strings.Join(safeVersions, ", "))
+	}
+
+	return version, nil
+}
+
+// detectChainID returns chain_id if it is configured, otherwise asks the RPC.
+func detectChainID() (uint64, error) {
+	if chainID := viper.GetUint64("chain_id"); chainID != 0 {
+		return chainID, nil
+	}
+
+	if viper.GetString("rpc_url") == "" {
+		return 0, errors.New("neither chain_id nor rpc_url is set")
+	}
+
+	provider, err := getProvider()
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), chainDetectTimeout)
+	defer cancel()
+
+	chainID, err := provider.ChainID(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return chainID.Uint64(), nil
+}
+
+// applyDeploymentRegistry fills the contract addresses that are not configured with the
+// official Safe deployment of the chain. The chain is taken from chain_id or, only when
+// an address is missing, from the RPC; without either the addresses stay unset. Only the
+// commands that use these addresses call it, so the others never reach the RPC for it.
+func applyDeploymentRegistry() error {
+	version, err := safeVersion()
+	if err != nil {
+		return err
+	}
+
+	var missing []string
+
+	for _, key := range deploymentKeys {
+		if viper.GetString(key) == "" {
+			missing = append(missing, key)
+		} else if addressSources[key] == "" {
+			addressSources[key] = "config"
+		}
+	}
+
+	if len(missing) == 0 || viper.GetUint64("chain_id") == 0 && viper.GetString("rpc_url") == "" {
+		return nil
+	}
+
+	chainID, err := detectChainID()
+	if err != nil {
+		log.Printf("Warning: cannot detect the chain to select the Safe deployment: %v", err)
+
+		return nil
+	}
+
+	deployment, ok, err := deploymentFor(chainID, version)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return nil
+	}
+
+	addresses := deployment.addresses(viper.GetBool("safe_l2"))
+
+	for _, key := range missing {
+		viper.SetDefault(key, addresses[key])
+		addressSources[key] = "registry " + version
+	}
+
+	return nil
+}
+
+func networksCommand() *command {
+	return &command{
+		name:        "networks",
+		args:        "",
+		description: "List network profiles and show the Safe contracts in use",
+		run:         nil,
+		subcommands: []*command{
+			networksListCommand(),
+			networksShowCommand(),
+		},
+	}
+}
+
+func networksListCommand() *command {
+	return &command{
+		name:        "list",
+		args:        "",
+		description: "List the network profiles",
+		run: func(fs *flag.FlagSet, args []string) error {
+			_, err := parseArgs(fs, args, 0)
+			if err != nil {
+				return err
+			}
+
+			networks, err := readNetworks()
+			if err != nil {
+				return err
+			}
+
+			for _, name := range profileNames(networks) {
+				profile := networks.Sub(name)
+				fmt.Printf("%-10s chain %-10d %s\n", name, profile.GetUint64("chain_id"), profile.GetString("rpc_url"))
+			}
+
+			return nil
+		},
+		subcommands: nil,
+	}
+}
+
+func networksShowCommand() *command {
+	return &command{
+		name:        "show",
+		args:        "",
+		description: "Show the chain and the Safe contract addresses the commands will use",
+		run: func(fs *flag.FlagSet, args []string) error {
+			_, err := parseArgs(fs, args, 0)
+			if err != nil {
+				return err
+			}
+
+			err = applyDeploymentRegistry()
+			if err != nil {
+				return err
+			}
+
+			chainID, err := detectChainID()
+			if err != nil {
+				return err
+			}
+
+			version, err := safeVersion()
+			if err != nil {
+				return err
+			}
+
+			fmt.Println("Network:            ", viper.GetString("network"))
+			fmt.Println("Chain ID:           ", chainID)
+			fmt.Println("Safe version:       ", version)
+			fmt.Println("SafeL2:             ", viper.GetBool("safe_l2"))
+
+			for _, key := range deploymentKeys {
+				value := viper.GetString(key)
+				if value == "" {
+					fmt.Printf("%-20s not set\n", key+":")
+
+					continue
+				}
+
+				if !common.IsHexAddress(value) {
+					return fmt.Errorf("%s: invalid address %q", key, value)
+				}
+
+				fmt.Printf("%-20s %s (%s)\n", key+":", common.HexToAddress(value).Hex(), addressSources[key])
+			}
+
+			return nil
+		},
+		subcommands: nil,
+	}
+}
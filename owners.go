@@ -0,0 +1,299 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/timofvy/multisig/abi/safe_abi"
+)
+
+func ownersCommand() *command {
+	return &command{
+		name:        "owners",
+		args:        "",
+		description: "Inspect and manage Safe owners",
+		run:         nil,
+		subcommands: []*command{
+			ownersListCommand(),
+			ownersAddCommand(),
+			ownersRemoveCommand(),
+			ownersSwapCommand(),
+		},
+	}
+}
+
+func ownersListCommand() *command {
+	return &command{
+		name:        "list",
+		args:        "<safe>",
+		description: "List the owners of a Safe",
+		run: func(fs *flag.FlagSet, args []string) error {
+			positional, err := parseArgs(fs, args, 1)
+			if err != nil {
+				return err
+			}
+
+			_, caller, _, err := openSafe(positional[0])
+			if err != nil {
+				return err
+			}
+
+			owners, err := caller.GetOwners(&bind.CallOpts{Context: context.Background()}) //nolint:exhaustruct
+			if err != nil {
+				return err
+			}
+
+			for _, owner := range owners {
+				fmt.Println(owner.Hex())
+			}
+
+			return nil
+		},
+		subcommands: nil,
+	}
+}
+
+// prevOwner returns the owner pointing to owner in the OwnerManager linked list, which getOwners
+// returns in list order starting after the sentinel.
+func prevOwner(owners []common.Address, owner common.Address) (common.Address, error) {
+	for i, current := range owners {
+		if current != owner {
+			continue
+		}
+
+		if i == 0 {
+			return common.HexToAddress(sentinelAddress), nil
+		}
+
+		return owners[i-1], nil
+	}
+
+	return common.Address{}, fmt.Errorf("%s is not an owner", owner.Hex())
+}
+
+func checkNewOwner(owners []common.Address, safe common.Address, owner common.Address) error {
+	err := validateOwners(append([]common.Address{owner}, owners...), 1)
+	if err != nil {
+		return fmt.Errorf("new owner %s: %w", owner.Hex(), err)
+	}
+
+	if owner == safe {
+		return fmt.Errorf("the Safe %s cannot own itself", safe.Hex())
+	}
+
+	return nil
+}
+
+func loadOwners(caller *safe_abi.SafeAbiCaller) ([]common.Address, int, error) {
+	opts := &bind.CallOpts{Context: context.Background()} //nolint:exhaustruct
+
+	owners, err := caller.GetOwners(opts)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	threshold, err := caller.GetThreshold(opts)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return owners, int(threshold.Int64()), nil
+}
+
+func checkNewThreshold(threshold int, ownerCount int) error {
+	if threshold < 1 {
+		return fmt.Errorf("threshold must be greater than 0, got %d", threshold)
+	}
+
+	if threshold > ownerCount {
+		return fmt.Errorf("threshold %d would exceed the new owner count %d", threshold, ownerCount)
+	}
+
+	return nil
+}
+
+func ownersAddCommand() *command {
+	return &command{
+		name:        "add",
+		args:        "<safe> <owner>",
+		description: "Build an addOwnerWithThreshold Safe transaction",
+		run: func(fs *flag.FlagSet, args []string) error {
+			thresholdFlag := fs.Int("threshold", 0, "New threshold, defaults to the current one")
+			selfTx := addSelfTxFlags(fs)
+
+			positional, err := parseArgs(fs, args, 2)
+			if err != nil {
+				return err
+			}
+
+			provider, caller, safe, err := openSafe(positional[0])
+			if err != nil {
+				return err
+			}
+
+			owner, err := parseAddress(positional[1])
+			if err != nil {
+				return err
+			}
+
+			owners, threshold, err := loadOwners(caller)
+			if err != nil {
+				return err
+			}
+
+			err = checkNewOwner(owners, safe, owner)
+			if err != nil {
+				return err
+			}
+
+			if *thresholdFlag != 0 {
+				threshold = *thresholdFlag
+			}
+
+			err = checkNewThreshold(threshold, len(owners)+1)
+			if err != nil {
+				return err
+			}
+
+			data, err := packSafeCall("addOwnerWithThreshold", owner, big.NewInt(int64(threshold)))
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Add owner %s, threshold %d of %d\n", owner.Hex(), threshold, len(owners)+1)
+
+			_, err = selfTx.run(provider, caller, safe, data)
+
+			return err
+		},
+		subcommands: nil,
+	}
+}
+
+func ownersRemoveCommand() *command {
+	return &command{
+		name:        "remove",
+		args:        "<safe> <owner>",
+		description: "Build a removeOwner Safe transaction",
+		run: func(fs *flag.FlagSet, args []string) error {
+			thresholdFlag := fs.Int("threshold", 0, "New threshold (default the current one)")
+			selfTx := addSelfTxFlags(fs)
+
+			positional, err := parseArgs(fs, args, 2)
+			if err != nil {
+				return err
+			}
+
+			provider, caller, safe, err := openSafe(positional[0])
+			if err != nil {
+				return err
+			}
+
+			owner, err := parseAddress(positional[1])
+			if err != nil {
+				return err
+			}
+
+			owners, threshold, err := loadOwners(caller)
+			if err != nil {
+				return err
+			}
+
+			prev, err := prevOwner(owners, owner)
+			if err != nil {
+				return err
+			}
+
+			if len(owners) == 1 {
+				return fmt.Errorf("cannot remove %s, it is the last owner", owner.Hex())
+			}
+
+			// Lowering the threshold silently would weaken the Safe, it has to be asked for.
+			if *thresholdFlag != 0 {
+				threshold = *thresholdFlag
+			} else if threshold > len(owners)-1 {
+				return fmt.Errorf("threshold %d would exceed the new owner count %d, pass --threshold to lower it",
+					threshold, len(owners)-1)
+			}
+
+			err = checkNewThreshold(threshold, len(owners)-1)
+			if err != nil {
+				return err
+			}
+
+			data, err := packSafeCall("removeOwner", prev, owner, big.NewInt(int64(threshold)))
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Remove owner %s (prev %s), threshold %d of %d\n", owner.Hex(), prev.Hex(), threshold, len(owners)-1)
+
+			_, err = selfTx.run(provider, caller, safe, data)
+
+			return err
+		},
+		subcommands: nil,
+	}
+}
+
+func ownersSwapCommand() *command {
+	return &command{
+		name:        "swap",
+		args:        "<safe> <old owner> <new owner>",
+		description: "Build a swapOwner Safe transaction",
+		run: func(fs *flag.FlagSet, args []string) error {
+			selfTx := addSelfTxFlags(fs)
+
+			positional, err := parseArgs(fs, args, 3)
+			if err != nil {
+				return err
+			}
+
+			provider, caller, safe, err := openSafe(positional[0])
+			if err != nil {
+				return err
+			}
+
+			oldOwner, err := parseAddress(positional[1])
+			if err != nil {
+				return err
+			}
+
+			newOwner, err := parseAddress(positional[2])
+			if err != nil {
+				return err
+			}
+
+			owners, _, err := loadOwners(caller)
+			if err != nil {
+				return err
+			}
+
+			prev, err := prevOwner(owners, oldOwner)
+			if err != nil {
+				return err
+			}
+
+			err = checkNewOwner(owners, safe, newOwner)
+			if err != nil {
+				return err
+			}
+
+			data, err := packSafeCall("swapOwner", prev, oldOwner, newOwner)
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Swap owner %s (prev %s) for %s\n", oldOwner.Hex(), prev.Hex(), newOwner.Hex())
+
+			_, err = selfTx.run(provider, caller, safe, data)
+
+			return err
+		},
+		subcommands: nil,
+	}
+}
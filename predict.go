@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/timofvy/multisig/abi/safe_proxy_factory_abi"
+)
+
+// predictSafeAddress reproduces SafeProxyFactory.deployProxy: the proxy is created with
+// CREATE2 using salt = keccak256(keccak256(initializer) ++ saltNonce) and init code
+// proxyCreationCode ++ uint256(singleton).
+func predictSafeAddress(
+	factory common.Address,
+	proxyCreationCode []byte,
+	singleton common.Address,
+	initializer []byte,
+	saltNonce *big.Int,
+) common.Address {
+	salt := crypto.Keccak256Hash(
+		crypto.Keccak256(initializer),
+		common.LeftPadBytes(saltNonce.Bytes(), 32),
+	)
+
+	deploymentData := make([]byte, 0, len(proxyCreationCode)+32)
+	deploymentData = append(deploymentData, proxyCreationCode...)
+	deploymentData = append(deploymentData, common.LeftPadBytes(singleton.Bytes(), 32)...)
+
+	return crypto.CreateAddress2(factory, salt, crypto.Keccak256(deploymentData))
+}
+
+func getProxyCreationCode(provider *ethclient.Client, factory common.Address) ([]byte, error) {
+	factoryCaller, err := safe_proxy_factory_abi.NewSafeProxyFactoryAbiCaller(factory, provider)
+	if err != nil {
+		return nil, err
+	}
+
+	code, err := factoryCaller.ProxyCreationCode(&bind.CallOpts{Context: context.Background()}) //nolint:exhaustruct
+	if err != nil {
+		return nil, err
+	}
+
+	if len(code) == 0 {
+		return nil, errors.New("safe proxy factory returned empty proxy creation code")
+	}
+
+	return code, nil
+}
+
+const maxAutoSaltAttempts = 1000
+
+// resolveSaltNonce returns the salt nonce to deploy with together with the predicted Safe address.
+// With autoSalt the nonce is incremented until the predicted address has no code, otherwise an
+// already occupied address is reported as an error because CreateProxyWithNonce would revert.
+func resolveSaltNonce(
+	provider *ethclient.Client,
+	factory common.Address,
+	proxyCreationCode []byte,
+	singleton common.Address,
+	initializer []byte,
+	saltNonce *big.Int,
+	autoSalt bool,
+) (*big.Int, common.Address, error) {
+	nonce := new(big.Int).Set(saltNonce)
+
+	for range maxAutoSaltAttempts {
+		predicted := predictSafeAddress(factory, proxyCreationCode, singleton, initializer, nonce)
+
+		code, err := provider.CodeAt(context.Background(), predicted, nil)
+		if err != nil {
+			return nil, common.Address{}, err
+		}
+
+		if len(code) == 0 {
+			return nonce, predicted, nil
+		}
+
+		if !autoSalt {
+			return nil, common.Address{}, fmt.Errorf(
+				"safe already deployed at %s with salt nonce %s, use --salt-nonce or --auto-salt",
+				predicted.Hex(),
+				nonce.String(),
+			)
+		}
+
+		log.Println("Salt nonce", nonce.String(), "is taken by", predicted.Hex())
+
+		nonce.Add(nonce, big.NewInt(1))
+	}
+
+	return nil, common.Address{}, fmt.Errorf("no free salt nonce found after %d attempts", maxAutoSaltAttempts)
+}
+
+func parseSaltNonce(value string) (*big.Int, error) {
+	saltNonce, ok := new(big.Int).SetString(value, 0)
+	if !ok {
+		return nil, fmt.Errorf("invalid salt nonce %q", value)
+	}
+
+	if saltNonce.Sign() < 0 || saltNonce.BitLen() > 256 {
+		return nil, fmt.Errorf("salt nonce %q is out of uint256 range", value)
+	}
+
+	return saltNonce, nil
+}
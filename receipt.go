@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/timofvy/multisig/abi/safe_proxy_factory_abi"
+)
+
+const receiptPollInterval = 2 * time.Second
+
+// waitForReceipt waits until the transaction is mined and buried under the requested number of
+// confirmations. The receipt is fetched again once confirmed so a reorged transaction is not reported.
+func waitForReceipt(
+	provider *ethclient.Client,
+	transaction *types.Transaction,
+	confirmations uint64,
+	timeout time.Duration,
+) (*types.Receipt, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	receipt, err := bind.WaitMined(ctx, provider, transaction)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, fmt.Errorf("transaction %s was not mined within %s", transaction.Hash().Hex(), timeout)
+		}
+
+		return nil, err
+	}
+
+	if confirmations <= 1 {
+		return receipt, nil
+	}
+
+	ticker := time.NewTicker(receiptPollInterval)
+	defer ticker.Stop()
+
+	for {
+		head, err := provider.BlockNumber(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		if head+1 >= receipt.BlockNumber.Uint64()+confirmations {
+			return provider.TransactionReceipt(ctx, transaction.Hash())
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf(
+				"transaction %s did not reach %d confirmations within %s",
+				transaction.Hash().Hex(),
+				confirmations,
+				timeout,
+			)
+		case <-ticker.C:
+		}
+	}
+}
+
+func parseProxyCreation(
+	provider *ethclient.Client,
+	factory common.Address,
+	receipt *types.Receipt,
+) (*safe_proxy_factory_abi.SafeProxyFactoryAbiProxyCreation, error) {
+	filterer, err := safe_proxy_factory_abi.NewSafeProxyFactoryAbiFilterer(factory, provider)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, receiptLog := range receipt.Logs {
+		if receiptLog.Address != factory {
+			continue
+		}
+
+		event, err := filterer.ParseProxyCreation(*receiptLog)
+		if err == nil {
+			return event, nil
+		}
+
+		proxy, singleton, ok := legacyEventData(*receiptLog, "ProxyCreation(address,address)")
+		if ok {
+			return &safe_proxy_factory_abi.SafeProxyFactoryAbiProxyCreation{
+				Proxy:     common.BytesToAddress(proxy.Bytes()),
+				Singleton: common.BytesToAddress(singleton.Bytes()),
+				Raw:       *receiptLog,
+			}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no ProxyCreation event in transaction %s", receipt.TxHash.Hex())
+}
+
+func reportReceipt(receipt *types.Receipt) {
+	log.Println("Block number: ", receipt.BlockNumber.String())
+	log.Println("Gas used: ", receipt.GasUsed)
+
+	if receipt.EffectiveGasPrice == nil {
+		return
+	}
+
+	fee := new(big.Int).Mul(receipt.EffectiveGasPrice, new(big.Int).SetUint64(receipt.GasUsed))
+
+	log.Println("Effective gas price (wei): ", receipt.EffectiveGasPrice.String())
+	log.Println("Fee (wei): ", fee.String())
+}
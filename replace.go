@@ -0,0 +1,713 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/spf13/viper"
+)
+
+const (
+	// minReplacementBump is the price bump in percent geth and most clients require
+	// before a transaction with the same nonce replaces a pending one.
+	minReplacementBump = 10
+
+	defaultJournalPath = "replacements.json"
+
+	replacementOriginal = "original"
+	replacementSpeedUp  = "speed-up"
+	replacementCancel   = "cancel"
+)
+
+type replacementEntry struct {
+	Hash   string `json:"hash"`
+	Kind   string `json:"kind"`
+	SentAt string `json:"sentAt,omitempty"`
+}
+
+// replacementGroup lists every transaction sent from one account with one nonce.
+// Only one of them can be mined; Mined records which one did.
+type replacementGroup struct {
+	ChainID      string             `json:"chainId"`
+	From         string             `json:"from"`
+	Nonce        uint64             `json:"nonce"`
+	Transactions []replacementEntry `json:"transactions"`
+	Mined        string             `json:"mined,omitempty"`
+}
+
+// replacementJournal is kept in a local file so a transaction replaced several times,
+// possibly from different runs, is tracked as one group.
+type replacementJournal struct {
+	Groups []*replacementGroup `json:"groups"`
+}
+
+func readJournal(path string) (*replacementJournal, error) {
+	journal := &replacementJournal{Groups: []*replacementGroup{}}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return journal, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(content, journal)
+	if err != nil {
+		return nil, fmt.Errorf("invalid journal %s: %w", path, err)
+	}
+
+	return journal, nil
+}
+
+func writeJournal(path string, journal *replacementJournal) error {
+	content, err := json.MarshalIndent(journal, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(content, '\n'), 0o644)
+}
+
+// group returns the group of the transaction, starting a new one with the transaction
+// as the original if none exists.
+func (j *replacementJournal) group(chainID *big.Int, from common.Address, tx *types.Transaction) *replacementGroup {
+	for _, group := range j.Groups {
+		if group.ChainID == chainID.String() && group.From == from.Hex() && group.Nonce == tx.Nonce() {
+			if !group.contains(tx.Hash()) {
+				group.Transactions = append(group.Transactions, replacementEntry{
+					Hash:   tx.Hash().Hex(),
+					Kind:   replacementOriginal,
+					SentAt: "",
+				})
+			}
+
+			return group
+		}
+	}
+
+	group := &replacementGroup{
+		ChainID: chainID.String(),
+		From:    from.Hex(),
+		Nonce:   tx.Nonce(),
+		Transactions: []replacementEntry{
+			{Hash: tx.Hash().Hex(), Kind: replacementOriginal, SentAt: ""},
+		},
+		Mined: "",
+	}
+
+	j.Groups = append(j.Groups, group)
+
+	return group
+}
+
+func (g *replacementGroup) contains(hash common.Hash) bool {
+	for _, entry := range g.Transactions {
+		if entry.Hash == hash.Hex() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// bumpFee raises fee by percent, rounding up so the bump is never short by a wei.
+func bumpFee(fee *big.Int, percent uint64) *big.Int {
+	bumped := new(big.Int).Mul(fee, new(big.Int).SetUint64(100+percent))
+	bumped.Add(bumped, big.NewInt(99))
+
+	return bumped.Div(bumped, big.NewInt(100))
+}
+
+func bigMax(a, b *big.Int) *big.Int {
+	if a.Cmp(b) >= 0 {
+		return a
+	}
+
+	return b
+}
+
+// replacementQuote picks fees for a replacement of old: at least old's fees bumped by
+// percent, raised to the current market price unless fixed by maxFee and priorityFee.
+func replacementQuote(
+	provider *ethclient.Client,
+	old *types.Transaction,
+	gasLimit uint64,
+	maxFee, priorityFee *big.Int,
+	percent uint64,
+) (feeQuote, error) {
+	ctx := context.Background()
+
+	switch old.Type() {
+	case types.LegacyTxType, types.AccessListTxType:
+		if priorityFee != nil {
+			return feeQuote{}, errors.New("--priority-fee cannot be used to replace a legacy transaction")
+		}
+
+		gasPrice := bumpFee(old.GasPrice(), percent)
+
+		if maxFee != nil {
+			if maxFee.Cmp(gasPrice) < 0 {
+				return feeQuote{}, fmt.Errorf("--max-fee %s gwei is below the required replacement gas price %s gwei",
+					formatGwei(maxFee), formatGwei(gasPrice))
+			}
+
+			gasPrice = maxFee
+		} else {
+			suggested, err := provider.SuggestGasPrice(ctx)
+			if err != nil {
+				return feeQuote{}, err
+			}
+
+			gasPrice = bigMax(gasPrice, suggested)
+		}
+
+		return feeQuote{gasLimit: gasLimit, gasPrice: gasPrice, gasFeeCap: nil, gasTipCap: nil}, nil
+	case types.DynamicFeeTxType:
+	default:
+		return feeQuote{}, fmt.Errorf("cannot replace a transaction of type %d", old.Type())
+	}
+
+	gasTipCap := bumpFee(old.GasTipCap(), percent)
+
+	if priorityFee != nil {
+		if priorityFee.Cmp(gasTipCap) < 0 {
+			return feeQuote{}, fmt.Errorf("--priority-fee %s gwei is below the required replacement tip %s gwei",
+				formatGwei(priorityFee), formatGwei(gasTipCap))
+		}
+
+		gasTipCap = priorityFee
+	} else {
+		suggested, err := provider.SuggestGasTipCap(ctx)
+		if err != nil {
+			return feeQuote{}, err
+		}
+
+		gasTipCap = bigMax(gasTipCap, suggested)
+	}
+
+	gasFeeCap := bumpFee(old.GasFeeCap(), percent)
+
+	if maxFee != nil {
+		if maxFee.Cmp(gasFeeCap) < 0 {
+			return feeQuote{}, fmt.Errorf("--max-fee %s gwei is below the required replacement max fee %s gwei",
+				formatGwei(maxFee), formatGwei(gasFeeCap))
+		}
+
+		gasFeeCap = maxFee
+	} else {
+		head, err := provider.HeaderByNumber(ctx, nil)
+		if err != nil {
+			return feeQuote{}, err
+		}
+
+		if head.BaseFee != nil {
+			market := new(big.Int).Add(gasTipCap, new(big.Int).Mul(head.BaseFee, big.NewInt(2)))
+			gasFeeCap = bigMax(gasFeeCap, market)
+		}
+	}
+
+	if gasFeeCap.Cmp(gasTipCap) < 0 {
+		if maxFee != nil {
+			return feeQuote{}, fmt.Errorf("--max-fee %s gwei is below the priority fee %s gwei",
+				formatGwei(gasFeeCap), formatGwei(gasTipCap))
+		}
+
+		gasFeeCap = gasTipCap
+	}
+
+	return feeQuote{gasLimit: gasLimit, gasPrice: nil, gasFeeCap: gasFeeCap, gasTipCap: gasTipCap}, nil
+}
+
+func newReplacementTx(
+	chainID *big.Int,
+	nonce uint64,
+	quote feeQuote,
+	to *common.Address,
+	value *big.Int,
+	data []byte,
+	accessList types.AccessList,
+) *types.Transaction {
+	if quote.gasPrice != nil {
+		return types.NewTx(&types.LegacyTx{
+			Nonce:    nonce,
+			GasPrice: quote.gasPrice,
+			Gas:      quote.gasLimit,
+			To:       to,
+			Value:    value,
+			Data:     data,
+			V:        nil,
+			R:        nil,
+			S:        nil,
+		})
+	}
+
+	return types.NewTx(&types.DynamicFeeTx{
+		ChainID:    chainID,
+		Nonce:      nonce,
+		GasTipCap:  quote.gasTipCap,
+		GasFeeCap:  quote.gasFeeCap,
+		Gas:        quote.gasLimit,
+		To:         to,
+		Value:      value,
+		Data:       data,
+		AccessList: accessList,
+		V:          nil,
+		R:          nil,
+		S:          nil,
+	})
+}
+
+// loadStuckTx fetches a pending transaction and checks that the configured signer sent it.
+func loadStuckTx(
+	provider *ethclient.Client,
+	value string,
+	signer Signer,
+) (*types.Transaction, *big.Int, error) {
+	ctx := context.Background()
+
+	hash, err := parseTxHash(value)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	chainID, err := provider.ChainID(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tx, isPending, err := provider.TransactionByHash(ctx, hash)
+	if errors.Is(err, ethereum.NotFound) {
+		return nil, nil, fmt.Errorf("transaction %s is unknown to the node, it may have been dropped or replaced", hash.Hex())
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !isPending {
+		return nil, nil, fmt.Errorf("transaction %s is already mined", hash.Hex())
+	}
+
+	from, err := types.Sender(types.LatestSignerForChainID(chainID), tx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if from != signer.Address() {
+		return nil, nil, fmt.Errorf("transaction %s was sent by %s, not by the signer %s",
+			hash.Hex(), from.Hex(), signer.Address().Hex())
+	}
+
+	nonce, err := provider.NonceAt(ctx, from, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if nonce > tx.Nonce() {
+		return nil, nil, fmt.Errorf("nonce %d of %s is already used by a mined transaction", tx.Nonce(), from.Hex())
+	}
+
+	return tx, chainID, nil
+}
+
+func parseTxHash(value string) (common.Hash, error) {
+	decoded, err := hexutil.Decode(value)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("invalid transaction hash %q: %w", value, err)
+	}
+
+	if len(decoded) != common.HashLength {
+		return common.Hash{}, fmt.Errorf("invalid transaction hash %q: expected %d bytes", value, common.HashLength)
+	}
+
+	return common.BytesToHash(decoded), nil
+}
+
+// findMined returns the entry of the group that is mined, if any.
+func findMined(ctx context.Context, provider *ethclient.Client, group *replacementGroup) (*replacementEntry, *types.Receipt, error) {
+	for i := range group.Transactions {
+		entry := &group.Transactions[i]
+
+		receipt, err := provider.TransactionReceipt(ctx, common.HexToHash(entry.Hash))
+		if errors.Is(err, ethereum.NotFound) {
+			continue
+		}
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return entry, receipt, nil
+	}
+
+	return nil, nil, nil
+}
+
+// waitForReplacement waits until one transaction of the group is mined and confirmed.
+// It fails early if the nonce gets used by a transaction outside the group.
+func waitForReplacement(
+	provider *ethclient.Client,
+	group *replacementGroup,
+	confirmations uint64,
+	timeout time.Duration,
+) (*replacementEntry, *types.Receipt, error) {
+	deadline := time.Now().Add(timeout)
+
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	ticker := time.NewTicker(receiptPollInterval)
+	defer ticker.Stop()
+
+	for {
+		entry, _, err := findMined(ctx, provider, group)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if entry != nil {
+			tx, _, err := provider.TransactionByHash(ctx, common.HexToHash(entry.Hash))
+			if err != nil {
+				return nil, nil, err
+			}
+
+			receipt, err := waitForReceipt(provider, tx, confirmations, time.Until(deadline))
+			if err != nil {
+				return nil, nil, err
+			}
+
+			return entry, receipt, nil
+		}
+
+		nonce, err := provider.NonceAt(ctx, common.HexToAddress(group.From), nil)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if nonce > group.Nonce {
+			return nil, nil, fmt.Errorf("nonce %d of %s was used by a transaction outside the journal", group.Nonce, group.From)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, nil, fmt.Errorf("no transaction with nonce %d of %s was mined within %s", group.Nonce, group.From, timeout)
+		case <-ticker.C:
+		}
+	}
+}
+
+type replaceFlags struct {
+	maxFee        *string
+	priorityFee   *string
+	bump          *uint64
+	maxSpend      *string
+	journal       *string
+	noWait        *bool
+	confirmations *uint64
+	timeout       *time.Duration
+}
+
+func addReplaceFlags(fs *flag.FlagSet) replaceFlags {
+	return replaceFlags{
+		maxFee: fs.String("max-fee", "",
+			"Max fee per gas in gwei, the gas price for legacy transactions (default bumped or market, whichever is higher)"),
+		priorityFee: fs.String("priority-fee", "",
+			"Max priority fee per gas in gwei (default bumped or market, whichever is higher)"),
+		bump:          fs.Uint64("bump", minReplacementBump, "Minimum fee increase over the pending transaction in percent"),
+		maxSpend:      fs.String("max-spend", viper.GetString("max_spend"), "Abort if gas limit x max fee + value exceeds this amount in ETH"),
+		journal:       fs.String("journal", defaultJournalPath, "File tracking the replacements of each nonce"),
+		noWait:        fs.Bool("no-wait", false, "Do not wait for one of the transactions to be mined"),
+		confirmations: fs.Uint64("confirmations", 1, "Number of confirmations to wait for"),
+		timeout:       fs.Duration("timeout", 5*time.Minute, "How long to wait for one of the transactions to be mined"),
+	}
+}
+
+// replace sends a transaction with the nonce of the stuck one. build receives the stuck
+// transaction and its sender and returns the replacement's recipient, value, data and gas.
+func (f replaceFlags) replace(
+	value string,
+	kind string,
+	build func(
+		provider *ethclient.Client,
+		old *types.Transaction,
+		from common.Address,
+	) (*common.Address, *big.Int, []byte, uint64, error),
+) error {
+	maxFee, err := parseOptionalUnits("max-fee", *f.maxFee, gweiDecimals)
+	if err != nil {
+		return err
+	}
+
+	priorityFee, err := parseOptionalUnits("priority-fee", *f.priorityFee, gweiDecimals)
+	if err != nil {
+		return err
+	}
+
+	maxSpend, err := parseOptionalUnits("max-spend", *f.maxSpend, etherDecimals)
+	if err != nil {
+		return err
+	}
+
+	if *f.bump < minReplacementBump {
+		return fmt.Errorf("--bump must be at least %d percent, nodes reject smaller replacements", minReplacementBump)
+	}
+
+	journal, err := readJournal(*f.journal)
+	if err != nil {
+		return err
+	}
+
+	provider, err := getProvider()
+	if err != nil {
+		return err
+	}
+
+	signer, err := loadSigner()
+	if err != nil {
+		return err
+	}
+
+	old, chainID, err := loadStuckTx(provider, value, signer)
+	if err != nil {
+		return err
+	}
+
+	from := signer.Address()
+	to, amount, data, gasLimit, err := build(provider, old, from)
+	if err != nil {
+		return err
+	}
+
+	quote, err := replacementQuote(provider, old, gasLimit, maxFee, priorityFee, *f.bump)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Replacing %s (nonce %d) with a %s transaction", old.Hash().Hex(), old.Nonce(), kind)
+	quote.report(amount)
+
+	err = checkMaxSpend(quote.maxCost(amount), maxSpend)
+	if err != nil {
+		return err
+	}
+
+	signed, err := signer.SignTx(newReplacementTx(chainID, old.Nonce(), quote, to, amount, data, old.AccessList()), chainID)
+	if err != nil {
+		return err
+	}
+
+	group := journal.group(chainID, from, old)
+
+	err = provider.SendTransaction(context.Background(), signed)
+	if err != nil {
+		return fmt.Errorf("send replacement: %w", err)
+	}
+
+	log.Println("Transaction sent: ", signed.Hash().Hex())
+
+	group.Transactions = append(group.Transactions, replacementEntry{
+		Hash:   signed.Hash().Hex(),
+		Kind:   kind,
+		SentAt: time.Now().UTC().Format(time.RFC3339),
+	})
+
+	err = writeJournal(*f.journal, journal)
+	if err != nil {
+		return err
+	}
+
+	if *f.noWait {
+		return nil
+	}
+
+	entry, receipt, err := waitForReplacement(provider, group, *f.confirmations, *f.timeout)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Mined: %s (%s)", entry.Hash, entry.Kind)
+	reportReceipt(receipt)
+
+	group.Mined = entry.Hash
+
+	return writeJournal(*f.journal, journal)
+}
+
+func stuckCommand() *command {
+	return &command{
+		name:        "stuck",
+		args:        "",
+		description: "Speed up or cancel a pending transaction sent by the signer",
+		run:         nil,
+		subcommands: []*command{
+			stuckSpeedUpCommand(),
+			stuckCancelCommand(),
+			stuckStatusCommand(),
+		},
+	}
+}
+
+func stuckSpeedUpCommand() *command {
+	return &command{
+		name:        "speed-up",
+		args:        "<tx hash>",
+		description: "Resend a pending transaction with the same nonce and higher fees",
+		run: func(fs *flag.FlagSet, args []string) error {
+			flags := addReplaceFlags(fs)
+			gasLimit := fs.Uint64("gas-limit", 0, "Gas limit (default the gas limit of the pending transaction)")
+
+			positional, err := parseArgs(fs, args, 1)
+			if err != nil {
+				return err
+			}
+
+			return flags.replace(
+				positional[0],
+				replacementSpeedUp,
+				func(
+					_ *ethclient.Client,
+					old *types.Transaction,
+					_ common.Address,
+				) (*common.Address, *big.Int, []byte, uint64, error) {
+					gas := old.Gas()
+					if *gasLimit != 0 {
+						gas = *gasLimit
+					}
+
+					return old.To(), old.Value(), old.Data(), gas, nil
+				},
+			)
+		},
+		subcommands: nil,
+	}
+}
+
+func stuckCancelCommand() *command {
+	return &command{
+		name:        "cancel",
+		args:        "<tx hash>",
+		description: "Replace a pending transaction with a zero-value transfer to the signer itself",
+		run: func(fs *flag.FlagSet, args []string) error {
+			flags := addReplaceFlags(fs)
+
+			positional, err := parseArgs(fs, args, 1)
+			if err != nil {
+				return err
+			}
+
+			return flags.replace(
+				positional[0],
+				replacementCancel,
+				func(
+					provider *ethclient.Client,
+					_ *types.Transaction,
+					from common.Address,
+				) (*common.Address, *big.Int, []byte, uint64, error) {
+					// Some chains charge more than 21000 for a plain transfer, e.g. Arbitrum
+					// adds the L1 data fee to the gas, so the transfer is estimated.
+					gas, err := provider.EstimateGas(context.Background(), ethereum.CallMsg{ //nolint:exhaustruct
+						From:  from,
+						To:    &from,
+						Value: big.NewInt(0),
+					})
+					if err != nil {
+						return nil, nil, nil, 0, fmt.Errorf("gas estimation failed: %s", decodeRevert(err))
+					}
+
+					return &from, big.NewInt(0), nil, max(gas, params.TxGas), nil
+				},
+			)
+		},
+		subcommands: nil,
+	}
+}
+
+func stuckStatusCommand() *command {
+	return &command{
+		name:        "status",
+		args:        "<tx hash>",
+		description: "Show which transaction of a replaced nonce was mined",
+		run: func(fs *flag.FlagSet, args []string) error {
+			journalPath := fs.String("journal", defaultJournalPath, "File tracking the replacements of each nonce")
+
+			positional, err := parseArgs(fs, args, 1)
+			if err != nil {
+				return err
+			}
+
+			hash, err := parseTxHash(positional[0])
+			if err != nil {
+				return err
+			}
+
+			journal, err := readJournal(*journalPath)
+			if err != nil {
+				return err
+			}
+
+			var group *replacementGroup
+
+			for _, candidate := range journal.Groups {
+				if candidate.contains(hash) {
+					group = candidate
+
+					break
+				}
+			}
+
+			if group == nil {
+				return fmt.Errorf("transaction %s is not in %s", hash.Hex(), *journalPath)
+			}
+
+			provider, err := getProvider()
+			if err != nil {
+				return err
+			}
+
+			entry, receipt, err := findMined(context.Background(), provider, group)
+			if err != nil {
+				return err
+			}
+
+			fmt.Println("From:  ", group.From)
+			fmt.Println("Nonce: ", group.Nonce)
+
+			for _, tx := range group.Transactions {
+				status := "pending or dropped"
+				if entry != nil {
+					status = "replaced"
+				}
+
+				if entry != nil && tx.Hash == entry.Hash {
+					status = fmt.Sprintf("mined in block %s", receipt.BlockNumber)
+				}
+
+				fmt.Printf("   %s %-8s %s\n", tx.Hash, tx.Kind, status)
+			}
+
+			if entry == nil || group.Mined == entry.Hash {
+				return nil
+			}
+
+			group.Mined = entry.Hash
+
+			return writeJournal(*journalPath, journal)
+		},
+		subcommands: nil,
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// safeErrorCodes describes the GSxxx revert codes used by the Safe contracts.
+var safeErrorCodes = map[string]string{
+	"GS000": "Could not finish initialization",
+	"GS001": "Threshold needs to be defined",
+	"GS010": "Not enough gas to execute Safe transaction",
+	"GS011": "Could not pay gas costs with ether",
+	"GS012": "Could not pay gas costs with token",
+	"GS013": "Safe transaction failed when gasPrice and safeTxGas were 0",
+	"GS020": "Signatures data too short",
+	"GS021": "Invalid contract signature location: inside static part",
+	"GS022": "Invalid contract signature location: length not present",
+	"GS023": "Invalid contract signature location: data not complete",
+	"GS024": "Invalid contract signature provided",
+	"GS025": "Hash has not been approved",
+	"GS026": "Invalid owner provided",
+	"GS030": "Only owners can approve a hash",
+	"GS031": "Method can only be called from this contract",
+	"GS100": "Modules have already been initialized",
+	"GS101": "Invalid module address provided",
+	"GS102": "Module has already been added",
+	"GS103": "Invalid prevModule, module pair provided",
+	"GS104": "Method can only be called from an enabled module",
+	"GS200": "Owners have already been setup",
+	"GS201": "Threshold cannot exceed owner count",
+	"GS202": "Threshold needs to be greater than 0",
+	"GS203": "Invalid owner address provided",
+	"GS204": "Address is already an owner",
+	"GS205": "Invalid prevOwner, owner pair provided",
+	"GS300": "Guard does not implement IERC165",
+	"GS400": "Fallback handler cannot be set to self",
+}
+
+// decodeRevert extracts the revert reason from an eth_call or eth_estimateGas error and
+// expands Safe GSxxx codes into readable messages.
+func decodeRevert(err error) string {
+	var dataErr rpc.DataError
+	if !errors.As(err, &dataErr) {
+		return err.Error()
+	}
+
+	data, ok := dataErr.ErrorData().(string)
+	if !ok {
+		return err.Error()
+	}
+
+	raw, decodeErr := hexutil.Decode(data)
+	if decodeErr != nil {
+		return err.Error()
+	}
+
+	reason, unpackErr := abi.UnpackRevert(raw)
+	if unpackErr != nil {
+		return fmt.Sprintf("%s (revert data %s)", err.Error(), data)
+	}
+
+	if description, known := safeErrorCodes[reason]; known {
+		return fmt.Sprintf("%s: %s", reason, description)
+	}
+
+	return reason
+}
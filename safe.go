@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/timofvy/multisig/abi/safe_abi"
+)
+
+const modulesPageSize = 50
+
+// keccak256("guard_manager.guard.address"), see GuardManager.sol.
+var guardSlot = common.HexToHash("0x4a204f620c8c5ccdca3fd54d003badd85ba500436a431f0cbda4f558c93c34c8")
+
+func openSafe(value string) (*ethclient.Client, *safe_abi.SafeAbiCaller, common.Address, error) {
+	safe, err := parseAddress(value)
+	if err != nil {
+		return nil, nil, common.Address{}, err
+	}
+
+	provider, err := getProvider()
+	if err != nil {
+		return nil, nil, common.Address{}, err
+	}
+
+	caller, err := safe_abi.NewSafeAbiCaller(safe, provider)
+	if err != nil {
+		return nil, nil, common.Address{}, err
+	}
+
+	return provider, caller, safe, nil
+}
+
+func readStorageAddress(caller *safe_abi.SafeAbiCaller, slot common.Hash) (common.Address, error) {
+	value, err := caller.GetStorageAt(
+		&bind.CallOpts{Context: context.Background()}, //nolint:exhaustruct
+		slot.Big(),
+		big.NewInt(1),
+	)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	return common.BytesToAddress(value), nil
+}
+
+// getModules walks the module linked list page by page starting from the sentinel.
+func getModules(caller *safe_abi.SafeAbiCaller) ([]common.Address, error) {
+	var modules []common.Address
+
+	start := common.HexToAddress(sentinelAddress)
+	for {
+		page, err := caller.GetModulesPaginated(
+			&bind.CallOpts{Context: context.Background()}, //nolint:exhaustruct
+			start,
+			big.NewInt(modulesPageSize),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		modules = append(modules, page.Array...)
+
+		if page.Next == (common.Address{}) || page.Next == common.HexToAddress(sentinelAddress) {
+			return modules, nil
+		}
+
+		start = page.Next
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/timofvy/multisig/abi/safe_abi"
+)
+
+const (
+	operationCall         uint8 = 0
+	operationDelegateCall uint8 = 1
+)
+
+// safeTx holds the fields of a Safe transaction as passed to execTransaction.
+type safeTx struct {
+	to             common.Address
+	value          *big.Int
+	data           []byte
+	operation      uint8
+	safeTxGas      *big.Int
+	baseGas        *big.Int
+	gasPrice       *big.Int
+	gasToken       common.Address
+	refundReceiver common.Address
+	nonce          *big.Int
+}
+
+type safeTxFlags struct {
+	to             *string
+	value          *string
+	data           *string
+	operation      *uint
+	safeTxGas      *string
+	baseGas        *string
+	gasPrice       *string
+	gasToken       *string
+	refundReceiver *string
+	nonce          *string
+}
+
+func addSafeTxFlags(fs *flag.FlagSet) safeTxFlags {
+	return safeTxFlags{
+		to:             fs.String("to", "", "Destination of the Safe transaction"),
+		value:          fs.String("value", "0", "Value in wei"),
+		data:           fs.String("data", "0x", "Hex calldata"),
+		operation:      fs.Uint("operation", 0, "0 for call, 1 for delegatecall"),
+		safeTxGas:      fs.String("safe-tx-gas", "0", "Gas for the inner transaction, 0 to use all available gas"),
+		baseGas:        fs.String("base-gas", "0", "Gas costs independent of the inner transaction, for refunds"),
+		gasPrice:       fs.String("gas-price", "0", "Gas price used for the refund, 0 for no refund"),
+		gasToken:       fs.String("gas-token", "", "Token used for the refund, empty for ETH"),
+		refundReceiver: fs.String("refund-receiver", "", "Receiver of the refund, empty for tx.origin"),
+		nonce:          fs.String("nonce", "", "Safe nonce, defaults to the current on-chain nonce"),
+	}
+}
+
+func parseUint256(name, value string) (*big.Int, error) {
+	number, ok := new(big.Int).SetString(value, 0)
+	if !ok || number.Sign() < 0 || number.BitLen() > 256 {
+		return nil, fmt.Errorf("%s: invalid uint256 %q", name, value)
+	}
+
+	return number, nil
+}
+
+// build turns the flags into a safeTx, reading the current nonce from the Safe when it is not given
+// and a caller is available.
+func (f safeTxFlags) build(caller *safe_abi.SafeAbiCaller) (safeTx, error) {
+	var (
+		tx  safeTx
+		err error
+	)
+
+	tx.to, err = parseAddress(*f.to)
+	if err != nil {
+		return safeTx{}, fmt.Errorf("to: %w", err)
+	}
+
+	if *f.operation > uint(operationDelegateCall) {
+		return safeTx{}, fmt.Errorf("operation: must be 0 or 1, got %d", *f.operation)
+	}
+
+	tx.operation = uint8(*f.operation)
+
+	tx.data, err = hexutil.Decode(*f.data)
+	if err != nil {
+		return safeTx{}, fmt.Errorf("data: %w", err)
+	}
+
+	numbers := []struct {
+		name   string
+		value  string
+		target **big.Int
+	}{
+		{"value", *f.value, &tx.value},
+		{"safe-tx-gas", *f.safeTxGas, &tx.safeTxGas},
+		{"base-gas", *f.baseGas, &tx.baseGas},
+		{"gas-price", *f.gasPrice, &tx.gasPrice},
+	}
+	for _, number := range numbers {
+		*number.target, err = parseUint256(number.name, number.value)
+		if err != nil {
+			return safeTx{}, err
+		}
+	}
+
+	tx.gasToken, err = parseOptionalAddress("gas-token", *f.gasToken)
+	if err != nil {
+		return safeTx{}, err
+	}
+
+	tx.refundReceiver, err = parseOptionalAddress("refund-receiver", *f.refundReceiver)
+	if err != nil {
+		return safeTx{}, err
+	}
+
+	if *f.nonce != "" {
+		tx.nonce, err = parseUint256("nonce", *f.nonce)
+		if err != nil {
+			return safeTx{}, err
+		}
+
+		return tx, nil
+	}
+
+	if caller == nil {
+		return safeTx{}, errors.New("nonce: required when the Safe is not queried")
+	}
+
+	tx.nonce, err = caller.Nonce(&bind.CallOpts{Context: context.Background()}) //nolint:exhaustruct
+	if err != nil {
+		return safeTx{}, err
+	}
+
+	return tx, nil
+}
+
+func getSafeTxHash(caller *safe_abi.SafeAbiCaller, tx safeTx) (common.Hash, error) {
+	hash, err := caller.GetTransactionHash(
+		&bind.CallOpts{Context: context.Background()}, //nolint:exhaustruct
+		tx.to,
+		tx.value,
+		tx.data,
+		tx.operation,
+		tx.safeTxGas,
+		tx.baseGas,
+		tx.gasPrice,
+		tx.gasToken,
+		tx.refundReceiver,
+		tx.nonce,
+	)
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	return hash, nil
+}
+
+func printSafeTx(safe common.Address, tx safeTx, hash common.Hash) {
+	fmt.Println("Safe:           ", safe.Hex())
+	fmt.Println("To:             ", tx.to.Hex())
+	fmt.Println("Value:          ", tx.value.String())
+	fmt.Println("Data:           ", hexutil.Encode(tx.data))
+	fmt.Println("Operation:      ", tx.operation)
+	fmt.Println("SafeTxGas:      ", tx.safeTxGas.String())
+	fmt.Println("BaseGas:        ", tx.baseGas.String())
+	fmt.Println("GasPrice:       ", tx.gasPrice.String())
+	fmt.Println("GasToken:       ", tx.gasToken.Hex())
+	fmt.Println("RefundReceiver: ", tx.refundReceiver.Hex())
+	fmt.Println("Nonce:          ", tx.nonce.String())
+	fmt.Println("SafeTxHash:     ", hash.Hex())
+}
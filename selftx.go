@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/timofvy/multisig/abi/safe_abi"
+)
+
+// selfTxFlags control what happens with a Safe transaction that calls the Safe itself, such as
+// owner or module changes: it is always printed and can be written to a file, signed and executed.
+type selfTxFlags struct {
+	nonce         *string
+	out           *string
+	sign          *bool
+	exec          *bool
+	signatures    *signatureFlags
+	confirmations *uint64
+	timeout       *time.Duration
+	fees          feeFlags
+}
+
+func addSelfTxFlags(fs *flag.FlagSet) selfTxFlags {
+	return selfTxFlags{
+		nonce:         fs.String("nonce", "", "Safe nonce, defaults to the current on-chain nonce"),
+		out:           fs.String("out", "", "Write the transaction file for offline signing"),
+		sign:          fs.Bool("sign", false, "Add a signature of the configured signer"),
+		exec:          fs.Bool("exec", false, "Execute the transaction with the collected signatures"),
+		signatures:    addSignatureFlags(fs),
+		confirmations: fs.Uint64("confirmations", 1, "Number of confirmations to wait for"),
+		timeout:       fs.Duration("timeout", 5*time.Minute, "How long to wait for the transaction to be confirmed"),
+		fees:          addFeeFlags(fs),
+	}
+}
+
+func packSafeCall(method string, args ...interface{}) ([]byte, error) {
+	contractAbi, err := abi.JSON(strings.NewReader(safe_abi.SafeAbiABI))
+	if err != nil {
+		return nil, err
+	}
+
+	return contractAbi.Pack(method, args...)
+}
+
+// run builds the self-call SafeTx with the given calldata and feeds it into the file, sign and
+// execute flow. The receipt is returned when the transaction was executed, nil otherwise.
+func (f selfTxFlags) run(
+	provider *ethclient.Client,
+	caller *safe_abi.SafeAbiCaller,
+	safe common.Address,
+	data []byte,
+) (*types.Receipt, error) {
+	tx := safeTx{
+		to:             safe,
+		value:          big.NewInt(0),
+		data:           data,
+		operation:      operationCall,
+		safeTxGas:      big.NewInt(0),
+		baseGas:        big.NewInt(0),
+		gasPrice:       big.NewInt(0),
+		gasToken:       common.Address{},
+		refundReceiver: common.Address{},
+		nonce:          nil,
+	}
+
+	fees, err := f.fees.settings()
+	if err != nil {
+		return nil, err
+	}
+
+	if *f.nonce != "" {
+		tx.nonce, err = parseUint256("nonce", *f.nonce)
+	} else {
+		tx.nonce, err = caller.Nonce(&bind.CallOpts{Context: context.Background()}) //nolint:exhaustruct
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	chainID, err := provider.ChainID(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := crossCheckSafeTxHash(caller, chainID, safe, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := pendingSafeTx{safe: safe, chainID: chainID, tx: tx, hash: hash}
+
+	printSafeTx(pending.safe, pending.tx, pending.hash)
+
+	signatures, err := f.signatures.collect(pending.hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if *f.sign {
+		signer, err := loadSigner()
+		if err != nil {
+			return nil, err
+		}
+
+		signature, err := signSafeTxHash(signer, pending.hash, false)
+		if err != nil {
+			return nil, err
+		}
+
+		signatures = mergeSignatures(signatures, signature)
+
+		fmt.Println("Signer:         ", signature.signer.Hex())
+		fmt.Println("Signature:      ", hexutil.Encode(signature.signature))
+	}
+
+	if *f.out != "" {
+		err = writeSafeTxFile(*f.out, pending, signatures)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !*f.exec {
+		return nil, nil //nolint:nilnil
+	}
+
+	return execSafeTx(pending, signatures, fees, *f.confirmations, *f.timeout)
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/timofvy/multisig/abi/safe_abi"
+)
+
+// setupParams holds every argument of Safe.setup.
+type setupParams struct {
+	owners          []common.Address
+	threshold       int
+	to              common.Address
+	data            []byte
+	fallbackHandler common.Address
+	paymentToken    common.Address
+	payment         *big.Int
+	paymentReceiver common.Address
+}
+
+func parseOptionalAddress(name, value string) (common.Address, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return common.Address{}, nil
+	}
+
+	address, err := parseAddress(value)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("%s: %w", name, err)
+	}
+
+	return address, nil
+}
+
+func newSetupParams(
+	owners []common.Address,
+	threshold int,
+	to string,
+	data string,
+	fallbackHandler string,
+	paymentToken string,
+	payment string,
+	paymentReceiver string,
+) (setupParams, error) {
+	params := setupParams{ //nolint:exhaustruct
+		owners:    owners,
+		threshold: threshold,
+	}
+
+	var err error
+
+	params.to, err = parseOptionalAddress("setup-to", to)
+	if err != nil {
+		return setupParams{}, err
+	}
+
+	if data != "" {
+		params.data, err = hexutil.Decode(data)
+		if err != nil {
+			return setupParams{}, fmt.Errorf("setup-data: %w", err)
+		}
+	}
+
+	params.fallbackHandler, err = parseOptionalAddress("fallback-handler", fallbackHandler)
+	if err != nil {
+		return setupParams{}, err
+	}
+
+	params.paymentToken, err = parseOptionalAddress("payment-token", paymentToken)
+	if err != nil {
+		return setupParams{}, err
+	}
+
+	params.payment = big.NewInt(0)
+	if payment != "" {
+		var ok bool
+
+		params.payment, ok = new(big.Int).SetString(payment, 10)
+		if !ok || params.payment.Sign() < 0 {
+			return setupParams{}, fmt.Errorf("payment: invalid amount %q", payment)
+		}
+	}
+
+	params.paymentReceiver, err = parseOptionalAddress("payment-receiver", paymentReceiver)
+	if err != nil {
+		return setupParams{}, err
+	}
+
+	return params, nil
+}
+
+func checkDeployParams(params setupParams) error {
+	err := validateOwners(params.owners, params.threshold)
+	if err != nil {
+		return err
+	}
+
+	if params.to == (common.Address{}) && len(params.data) > 0 {
+		return errors.New("setup-data requires setup-to")
+	}
+
+	if params.to != (common.Address{}) && len(params.data) == 0 {
+		return errors.New("setup-to requires setup-data")
+	}
+
+	if params.payment.Sign() == 0 {
+		if params.paymentToken != (common.Address{}) {
+			return errors.New("payment-token requires a non-zero payment")
+		}
+
+		if params.paymentReceiver != (common.Address{}) {
+			return errors.New("payment-receiver requires a non-zero payment")
+		}
+	}
+
+	return nil
+}
+
+func packSetup(params setupParams) ([]byte, error) {
+	contractAbi, err := abi.JSON(strings.NewReader(safe_abi.SafeAbiABI))
+	if err != nil {
+		return nil, err
+	}
+
+	data := params.data
+	if data == nil {
+		data = []byte{}
+	}
+
+	return contractAbi.Pack("setup",
+		params.owners,
+		big.NewInt(int64(params.threshold)),
+		params.to,
+		data,
+		params.fallbackHandler,
+		params.paymentToken,
+		params.payment,
+		params.paymentReceiver,
+	)
+}
+
+// checkSetupOnChain validates the parts of setup that depend on chain state: Safe.setupModules
+// requires `to` to be a contract, a fallback handler without code is useless, and handlePayment
+// reverts unless the future Safe address is already funded.
+func checkSetupOnChain(provider *ethclient.Client, params setupParams, safe common.Address) error {
+	for _, owner := range params.owners {
+		if owner == safe {
+			return fmt.Errorf("owner %s is the predicted Safe address itself", owner.Hex())
+		}
+	}
+
+	err := warnContractOwners(provider, params.owners)
+	if err != nil {
+		return err
+	}
+
+	if params.to != (common.Address{}) {
+		code, err := provider.CodeAt(context.Background(), params.to, nil)
+		if err != nil {
+			return err
+		}
+
+		if len(code) == 0 {
+			return fmt.Errorf("setup-to %s has no contract code", params.to.Hex())
+		}
+	}
+
+	if params.fallbackHandler != (common.Address{}) {
+		code, err := provider.CodeAt(context.Background(), params.fallbackHandler, nil)
+		if err != nil {
+			return err
+		}
+
+		if len(code) == 0 {
+			return fmt.Errorf("fallback handler %s has no contract code", params.fallbackHandler.Hex())
+		}
+	}
+
+	if params.payment.Sign() == 0 {
+		return nil
+	}
+
+	if params.paymentReceiver == (common.Address{}) {
+		log.Println("Payment receiver is not set, the payment goes to the transaction sender")
+	}
+
+	balance, err := paymentBalance(provider, params.paymentToken, safe)
+	if err != nil {
+		return err
+	}
+
+	if balance.Cmp(params.payment) < 0 {
+		return fmt.Errorf(
+			"predicted Safe %s holds %s, not enough for payment %s",
+			safe.Hex(),
+			balance.String(),
+			params.payment.String(),
+		)
+	}
+
+	return nil
+}
+
+func paymentBalance(provider *ethclient.Client, token common.Address, account common.Address) (*big.Int, error) {
+	if token == (common.Address{}) {
+		return provider.BalanceAt(context.Background(), account, nil)
+	}
+
+	// balanceOf(address)
+	calldata := append(common.FromHex("0x70a08231"), common.LeftPadBytes(account.Bytes(), 32)...)
+
+	result, err := provider.CallContract(context.Background(), ethereum.CallMsg{ //nolint:exhaustruct
+		To:   &token,
+		Data: calldata,
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result) < 32 {
+		return nil, fmt.Errorf("payment token %s returned invalid balanceOf result", token.Hex())
+	}
+
+	return new(big.Int).SetBytes(result[:32]), nil
+}
+
+type setupFlags struct {
+	fs              *flag.FlagSet
+	owners          *string
+	threshold       *int
+	to              *string
+	data            *string
+	paymentToken    *string
+	payment         *string
+	paymentReceiver *string
+}
+
+func addSetupFlags(fs *flag.FlagSet) setupFlags {
+	// Read through flagOrConfig once the registry is applied.
+	fs.String("fallback-handler", "", "Fallback handler address (default fallback_handler)")
+
+	return setupFlags{
+		fs:              fs,
+		owners:          fs.String("owners", "", "Comma separated owner addresses"),
+		threshold:       fs.Int("threshold", 0, "Number of required confirmations"),
+		to:              fs.String("setup-to", "", "Contract called via delegatecall during setup, e.g. to enable modules"),
+		data:            fs.String("setup-data", "", "Hex calldata for the setup delegatecall"),
+		paymentToken:    fs.String("payment-token", "", "Token used for the setup payment, empty for ETH"),
+		payment:         fs.String("payment", "", "Setup payment amount in the smallest token unit"),
+		paymentReceiver: fs.String("payment-receiver", "", "Receiver of the setup payment, empty for tx.origin"),
+	}
+}
+
+func (f setupFlags) params() (setupParams, error) {
+	owners, err := parseOwners(*f.owners)
+	if err != nil {
+		return setupParams{}, err
+	}
+
+	return newSetupParams(
+		owners,
+		*f.threshold,
+		*f.to,
+		*f.data,
+		flagOrConfig(f.fs, "fallback-handler", "fallback_handler"),
+		*f.paymentToken,
+		*f.payment,
+		*f.paymentReceiver,
+	)
+}
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/timofvy/multisig/abi/safe_abi"
+)
+
+const signatureLength = 65
+
+// Signature types accepted by Safe.checkNSignatures, told apart by the v byte.
+const (
+	signatureTypeEOA      = "eoa"      // v = 27 or 28, ECDSA over the SafeTx hash
+	signatureTypeEthSign  = "eth_sign" // v = 31 or 32, ECDSA over the eth_sign prefixed SafeTx hash
+	signatureTypeApproved = "approved" // v = 1, hash approved on-chain via approveHash or sent by the owner
+	signatureTypeContract = "contract" // v = 0, EIP-1271 signature of a contract owner
+)
+
+// ownerSignature is a single owner's signature. For eoa and eth_sign signature holds r, s, v,
+// for contract it holds the EIP-1271 signature data and for approved it is empty.
+type ownerSignature struct {
+	signer    common.Address
+	kind      string
+	signature []byte
+}
+
+func ethSignHash(hash common.Hash) common.Hash {
+	return common.BytesToHash(accounts.TextHash(hash.Bytes()))
+}
+
+// recoverECDSA returns the signer of an eoa or eth_sign signature over the SafeTx hash.
+func recoverECDSA(hash common.Hash, kind string, signature []byte) (common.Address, error) {
+	if len(signature) != signatureLength {
+		return common.Address{}, fmt.Errorf("signature must be %d bytes, got %d", signatureLength, len(signature))
+	}
+
+	normalized := bytes.Clone(signature)
+
+	switch v := signature[crypto.RecoveryIDOffset]; {
+	case kind == signatureTypeEOA && (v == 27 || v == 28):
+		normalized[crypto.RecoveryIDOffset] -= 27
+	case kind == signatureTypeEthSign && (v == 31 || v == 32):
+		normalized[crypto.RecoveryIDOffset] -= 31
+		hash = ethSignHash(hash)
+	default:
+		return common.Address{}, fmt.Errorf("invalid v=%d for %s signature", v, kind)
+	}
+
+	publicKey, err := crypto.SigToPub(hash.Bytes(), normalized)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	return crypto.PubkeyToAddress(*publicKey), nil
+}
+
+// newECDSASignature detects eoa or eth_sign from v and recovers the signer.
+func newECDSASignature(hash common.Hash, signature []byte) (ownerSignature, error) {
+	kind := signatureTypeEOA
+	if len(signature) == signatureLength && signature[crypto.RecoveryIDOffset] > 30 {
+		kind = signatureTypeEthSign
+	}
+
+	signer, err := recoverECDSA(hash, kind, signature)
+	if err != nil {
+		return ownerSignature{}, err
+	}
+
+	return ownerSignature{signer: signer, kind: kind, signature: signature}, nil
+}
+
+// validate checks that an ECDSA signature was produced by its signer, other types can only be
+// verified on-chain.
+func (s ownerSignature) validate(hash common.Hash) error {
+	switch s.kind {
+	case signatureTypeEOA, signatureTypeEthSign:
+		recovered, err := recoverECDSA(hash, s.kind, s.signature)
+		if err != nil {
+			return err
+		}
+
+		if recovered != s.signer {
+			return fmt.Errorf("%s signature of %s was produced by %s", s.kind, s.signer.Hex(), recovered.Hex())
+		}
+	case signatureTypeApproved:
+		if len(s.signature) != 0 {
+			return fmt.Errorf("approved signature of %s must not carry data", s.signer.Hex())
+		}
+	case signatureTypeContract:
+	default:
+		return fmt.Errorf("signature of %s: unsupported type %q", s.signer.Hex(), s.kind)
+	}
+
+	return nil
+}
+
+// signSafeTxHash signs the SafeTx hash as an eoa signature or, with ethSign, the way eth_sign and
+// personal_sign wallets do, shifting v by 4 so the Safe applies the message prefix. Signers that
+// cannot sign raw hashes, such as Clef, always produce eth_sign signatures.
+func signSafeTxHash(signer Signer, hash common.Hash, ethSign bool) (ownerSignature, error) {
+	var (
+		signature []byte
+		err       error
+	)
+
+	if !ethSign {
+		signature, err = signer.SignHash(hash)
+		if errors.Is(err, errRawHashUnsupported) {
+			log.Println("The signer cannot sign raw hashes, signing the SafeTx hash as eth_sign instead")
+
+			ethSign = true
+		} else if err != nil {
+			return ownerSignature{}, err
+		}
+	}
+
+	kind := signatureTypeEOA
+	offset := byte(27)
+
+	if ethSign {
+		kind = signatureTypeEthSign
+		offset = 31
+
+		signature, err = signer.SignText(hash.Bytes())
+		if err != nil {
+			return ownerSignature{}, err
+		}
+	}
+
+	if len(signature) != signatureLength {
+		return ownerSignature{}, fmt.Errorf("signer returned a %d byte signature", len(signature))
+	}
+
+	signature[crypto.RecoveryIDOffset] += offset
+
+	result := ownerSignature{
+		signer:    signer.Address(),
+		kind:      kind,
+		signature: signature,
+	}
+
+	// External signers are not trusted to have signed with the expected account.
+	err = result.validate(hash)
+	if err != nil {
+		return ownerSignature{}, err
+	}
+
+	return result, nil
+}
+
+// encodeSignatures builds the signatures bytes for execTransaction: the 65 byte static parts sorted by
+// signer, as checkSignatures requires strictly increasing owners, followed by the dynamic parts of
+// contract signatures, each prefixed with its length and referenced by offset from the static part.
+func encodeSignatures(signatures []ownerSignature) ([]byte, error) {
+	sorted := make([]ownerSignature, len(signatures))
+	copy(sorted, signatures)
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i].signer.Bytes(), sorted[j].signer.Bytes()) < 0
+	})
+
+	static := make([]byte, 0, len(sorted)*signatureLength)
+	dynamic := []byte{}
+
+	for i, signature := range sorted {
+		if i > 0 && sorted[i-1].signer == signature.signer {
+			return nil, fmt.Errorf("duplicate signature from %s", signature.signer.Hex())
+		}
+
+		switch signature.kind {
+		case signatureTypeEOA, signatureTypeEthSign:
+			static = append(static, signature.signature...)
+		case signatureTypeApproved:
+			static = append(static, word(signature.signer.Bytes())...)
+			static = append(static, word(nil)...)
+			static = append(static, 1)
+		case signatureTypeContract:
+			offset := len(sorted)*signatureLength + len(dynamic)
+
+			static = append(static, word(signature.signer.Bytes())...)
+			static = append(static, word(big.NewInt(int64(offset)).Bytes())...)
+			static = append(static, 0)
+
+			dynamic = append(dynamic, word(big.NewInt(int64(len(signature.signature))).Bytes())...)
+			dynamic = append(dynamic, signature.signature...)
+		default:
+			return nil, fmt.Errorf("signature of %s: unsupported type %q", signature.signer.Hex(), signature.kind)
+		}
+	}
+
+	return append(static, dynamic...), nil
+}
+
+// decodeSignatures splits execTransaction signatures bytes back into owner signatures, recovering
+// ECDSA signers. The static part ends where the first contract signature data starts.
+func decodeSignatures(hash common.Hash, packed []byte) ([]ownerSignature, error) {
+	var signatures []ownerSignature
+
+	end := len(packed)
+	for position := 0; position+signatureLength <= end; position += signatureLength {
+		chunk := packed[position : position+signatureLength]
+		v := chunk[crypto.RecoveryIDOffset]
+
+		if v > 1 {
+			signature, err := newECDSASignature(hash, bytes.Clone(chunk))
+			if err != nil {
+				return nil, err
+			}
+
+			signatures = append(signatures, signature)
+
+			continue
+		}
+
+		if !bytes.Equal(chunk[:12], make([]byte, 12)) {
+			return nil, fmt.Errorf("signature #%d: r is not an address", len(signatures)+1)
+		}
+
+		signer := common.BytesToAddress(chunk[:32])
+
+		if v == 1 {
+			signatures = append(signatures, ownerSignature{signer: signer, kind: signatureTypeApproved, signature: nil})
+
+			continue
+		}
+
+		offset := new(big.Int).SetBytes(chunk[32:64])
+		if !offset.IsInt64() || offset.Int64() < int64(position+signatureLength) || offset.Int64()+32 > int64(len(packed)) {
+			return nil, fmt.Errorf("signature #%d: invalid contract signature offset %s", len(signatures)+1, offset)
+		}
+
+		start := int(offset.Int64())
+
+		length := new(big.Int).SetBytes(packed[start : start+32])
+		if !length.IsInt64() || int64(start)+32+length.Int64() > int64(len(packed)) {
+			return nil, fmt.Errorf("signature #%d: contract signature data is not complete", len(signatures)+1)
+		}
+
+		signatures = append(signatures, ownerSignature{
+			signer:    signer,
+			kind:      signatureTypeContract,
+			signature: bytes.Clone(packed[start+32 : start+32+int(length.Int64())]),
+		})
+
+		end = min(end, start)
+	}
+
+	if len(signatures) == 0 {
+		return nil, errors.New("no signatures found")
+	}
+
+	return signatures, nil
+}
+
+type signatureFlags struct {
+	signatures         stringList
+	ethSignSignatures  stringList
+	approved           stringList
+	contractSignatures stringList
+}
+
+func addSignatureFlags(fs *flag.FlagSet) *signatureFlags {
+	f := &signatureFlags{} //nolint:exhaustruct
+
+	fs.Var(&f.signatures, "signature", "Safe encoded signature bytes in hex of any type, repeatable")
+	fs.Var(&f.ethSignSignatures, "eth-sign-signature", "personal_sign signature with v 27 or 28 of the SafeTx hash, repeatable")
+	fs.Var(&f.approved, "approved", "Owner that approved the hash on-chain or executes the transaction, repeatable")
+	fs.Var(&f.contractSignatures, "contract-signature", "EIP-1271 signature as owner=0xdata, repeatable")
+
+	return f
+}
+
+func (f *signatureFlags) collect(hash common.Hash) ([]ownerSignature, error) {
+	var signatures []ownerSignature
+
+	for _, value := range f.signatures {
+		raw, err := hexutil.Decode(value)
+		if err != nil {
+			return nil, fmt.Errorf("signature %q: %w", value, err)
+		}
+
+		decoded, err := decodeSignatures(hash, raw)
+		if err != nil {
+			return nil, fmt.Errorf("signature %q: %w", value, err)
+		}
+
+		signatures = append(signatures, decoded...)
+	}
+
+	for _, value := range f.ethSignSignatures {
+		raw, err := hexutil.Decode(value)
+		if err != nil {
+			return nil, fmt.Errorf("eth-sign-signature %q: %w", value, err)
+		}
+
+		if len(raw) != signatureLength || raw[crypto.RecoveryIDOffset] < 27 || raw[crypto.RecoveryIDOffset] > 28 {
+			return nil, fmt.Errorf("eth-sign-signature %q: expected %d bytes with v 27 or 28", value, signatureLength)
+		}
+
+		raw[crypto.RecoveryIDOffset] += 4
+
+		signature, err := newECDSASignature(hash, raw)
+		if err != nil {
+			return nil, err
+		}
+
+		signatures = append(signatures, signature)
+	}
+
+	for _, value := range f.approved {
+		owner, err := parseAddress(value)
+		if err != nil {
+			return nil, fmt.Errorf("approved: %w", err)
+		}
+
+		signatures = append(signatures, ownerSignature{signer: owner, kind: signatureTypeApproved, signature: nil})
+	}
+
+	for _, value := range f.contractSignatures {
+		ownerValue, dataValue, ok := strings.Cut(value, "=")
+		if !ok {
+			return nil, fmt.Errorf("contract-signature %q: expected owner=0xdata", value)
+		}
+
+		owner, err := parseAddress(ownerValue)
+		if err != nil {
+			return nil, fmt.Errorf("contract-signature: %w", err)
+		}
+
+		data, err := hexutil.Decode(dataValue)
+		if err != nil {
+			return nil, fmt.Errorf("contract-signature %q: %w", value, err)
+		}
+
+		signatures = append(signatures, ownerSignature{signer: owner, kind: signatureTypeContract, signature: data})
+	}
+
+	return signatures, nil
+}
+
+// checkSafeSignatures verifies the signers against the owner list, approved hashes and threshold and
+// then runs the contract's own checkSignatures from the executor's address, so a bad set of signatures,
+// including EIP-1271 ones, fails before broadcasting.
+func checkSafeSignatures(
+	caller *safe_abi.SafeAbiCaller,
+	executor common.Address,
+	hash common.Hash,
+	txData []byte,
+	signatures []ownerSignature,
+	packed []byte,
+) error {
+	opts := &bind.CallOpts{Context: context.Background(), From: executor} //nolint:exhaustruct
+
+	for _, signature := range signatures {
+		isOwner, err := caller.IsOwner(opts, signature.signer)
+		if err != nil {
+			return err
+		}
+
+		if !isOwner {
+			return fmt.Errorf("signer %s is not an owner", signature.signer.Hex())
+		}
+
+		if signature.kind != signatureTypeApproved || signature.signer == executor {
+			continue
+		}
+
+		approved, err := caller.ApprovedHashes(opts, signature.signer, hash)
+		if err != nil {
+			return err
+		}
+
+		if approved.Sign() == 0 {
+			return fmt.Errorf("owner %s has not approved hash %s", signature.signer.Hex(), hash.Hex())
+		}
+	}
+
+	threshold, err := caller.GetThreshold(opts)
+	if err != nil {
+		return err
+	}
+
+	if threshold.Cmp(big.NewInt(int64(len(signatures)))) > 0 {
+		return fmt.Errorf("%d signatures collected, threshold is %s", len(signatures), threshold.String())
+	}
+
+	err = caller.CheckSignatures(opts, hash, txData, packed)
+	if err != nil {
+		return errors.New("checkSignatures: " + decodeRevert(err))
+	}
+
+	return nil
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"math/big"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var signatureTestHash = common.HexToHash("0x23e4e9cb9be1c0eb1ca6cec92e39a3f0f561d6235b4ed75b6217ba12c9468af2")
+
+func testSignature(t *testing.T, hexKey string, ethSign bool) ownerSignature {
+	t.Helper()
+
+	key, err := crypto.HexToECDSA(hexKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signature, err := signSafeTxHash(keySigner{key: key}, signatureTestHash, ethSign)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signature
+}
+
+// staticPart builds a 65 byte signature part for approved and contract signatures.
+func staticPart(r []byte, s *big.Int, v byte) []byte {
+	part := append(word(r), word(s.Bytes())...)
+
+	return append(part, v)
+}
+
+func TestEncodeDecodeSignatures(t *testing.T) {
+	t.Parallel()
+
+	eoa := testSignature(t, "ac0974bec39a17e36ba4a6b4d238ff944bacb478cbed5efcae784d7bf4f2ff80", false)
+	ethSign := testSignature(t, "59c6995e998f97a5a0044966f0945389dc9e86dae88c7a8412f4603b6b78690d", true)
+	approved := ownerSignature{
+		signer:    common.HexToAddress("0x3C44CdDdB6a900fa2b585dd299e03d12FA4293BC"),
+		kind:      signatureTypeApproved,
+		signature: nil,
+	}
+	contract := ownerSignature{
+		signer:    common.HexToAddress("0x1000000000000000000000000000000000000001"),
+		kind:      signatureTypeContract,
+		signature: hexutil.MustDecode("0xdeadbeef"),
+	}
+	emptyContract := ownerSignature{
+		signer:    common.HexToAddress("0xF000000000000000000000000000000000000002"),
+		kind:      signatureTypeContract,
+		signature: []byte{},
+	}
+
+	tests := []struct {
+		name       string
+		signatures []ownerSignature
+		length     int
+		err        string
+	}{
+		{name: "eoa", signatures: []ownerSignature{eoa}, length: 65, err: ""},
+		{name: "eth_sign", signatures: []ownerSignature{ethSign}, length: 65, err: ""},
+		{name: "approved", signatures: []ownerSignature{approved}, length: 65, err: ""},
+		{name: "contract", signatures: []ownerSignature{contract}, length: 65 + 32 + 4, err: ""},
+		{
+			name:       "mixed",
+			signatures: []ownerSignature{contract, eoa, emptyContract, approved, ethSign},
+			length:     5*65 + 32 + 4 + 32,
+			err:        "",
+		},
+		{name: "duplicate signer", signatures: []ownerSignature{eoa, eoa}, length: 0, err: "duplicate signature"},
+		{
+			name: "unsupported type",
+			signatures: []ownerSignature{
+				{signer: approved.signer, kind: "unknown", signature: nil},
+			},
+			length: 0,
+			err:    "unsupported type",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			packed, err := encodeSignatures(test.signatures)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("error %v, want %q", err, test.err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(packed) != test.length {
+				t.Fatalf("encoded %d bytes, want %d", len(packed), test.length)
+			}
+
+			decoded, err := decodeSignatures(signatureTestHash, packed)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := append([]ownerSignature{}, test.signatures...)
+			sort.Slice(want, func(i, j int) bool {
+				return bytes.Compare(want[i].signer.Bytes(), want[j].signer.Bytes()) < 0
+			})
+
+			if len(decoded) != len(want) {
+				t.Fatalf("decoded %d signatures, want %d", len(decoded), len(want))
+			}
+
+			for i := range want {
+				if decoded[i].signer != want[i].signer || decoded[i].kind != want[i].kind ||
+					!bytes.Equal(decoded[i].signature, want[i].signature) {
+					t.Errorf("signature #%d: got %s %s %x, want %s %s %x", i+1,
+						decoded[i].kind, decoded[i].signer.Hex(), decoded[i].signature,
+						want[i].kind, want[i].signer.Hex(), want[i].signature)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeMalformedSignatures(t *testing.T) {
+	t.Parallel()
+
+	owner := common.HexToAddress("0x1000000000000000000000000000000000000001").Bytes()
+	offset := func(value int64) *big.Int { return big.NewInt(value) }
+	concat := func(parts ...[]byte) []byte { return bytes.Join(parts, nil) }
+
+	tests := []struct {
+		name   string
+		packed []byte
+		err    string
+	}{
+		{name: "empty", packed: nil, err: "no signatures found"},
+		{name: "shorter than a signature", packed: make([]byte, 64), err: "no signatures found"},
+		{
+			name:   "r is not an address",
+			packed: staticPart(bytes.Repeat([]byte{0xff}, 32), offset(0), 1),
+			err:    "r is not an address",
+		},
+		{
+			name:   "invalid v",
+			packed: staticPart(owner, offset(0), 29),
+			err:    "invalid v=29",
+		},
+		{
+			name:   "offset inside the static part",
+			packed: concat(staticPart(owner, offset(0), 0), word(nil)),
+			err:    "invalid contract signature offset 0",
+		},
+		{
+			name:   "offset past the end",
+			packed: concat(staticPart(owner, offset(65), 0), make([]byte, 31)),
+			err:    "invalid contract signature offset 65",
+		},
+		{
+			name:   "offset overflows",
+			packed: concat(staticPart(owner, new(big.Int).Lsh(big.NewInt(1), 255), 0), word(nil)),
+			err:    "invalid contract signature offset",
+		},
+		{
+			name:   "length past the end",
+			packed: concat(staticPart(owner, offset(65), 0), word(big.NewInt(5).Bytes()), []byte{1, 2, 3, 4}),
+			err:    "contract signature data is not complete",
+		},
+		{
+			name: "length overflows",
+			packed: concat(staticPart(owner, offset(65), 0),
+				word(new(big.Int).Lsh(big.NewInt(1), 255).Bytes())),
+			err: "contract signature data is not complete",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := decodeSignatures(signatureTestHash, test.packed)
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("error %v, want %q", err, test.err)
+			}
+		})
+	}
+}
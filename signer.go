@@ -0,0 +1,363 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/external"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/spf13/viper"
+	"github.com/tyler-smith/go-bip39"
+	"golang.org/x/term"
+)
+
+const (
+	signerPrivateKey = "private-key"
+	signerKeystore   = "keystore"
+	signerMnemonic   = "mnemonic"
+	signerExternal   = "external"
+
+	defaultHDPath = "m/44'/60'/0'/0/0"
+)
+
+var (
+	errNoSigner = errors.New(
+		"signer is not configured, set one of private_key, keystore, mnemonic or external_signer",
+	)
+	errRawHashUnsupported = errors.New("signer cannot sign raw hashes")
+)
+
+// Signer signs on behalf of one account: the transactions sent by the CLI and the
+// SafeTx hashes it adds owner signatures for. Signatures are 65 bytes with v of 0 or 1.
+type Signer interface {
+	Address() common.Address
+	SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error)
+	// SignHash signs a 32 byte digest as is.
+	SignHash(hash common.Hash) ([]byte, error)
+	// SignText signs data with the EIP-191 personal message prefix, like eth_sign.
+	SignText(data []byte) ([]byte, error)
+}
+
+// keySigner holds the private key in memory; the private key, keystore and mnemonic
+// backends all end up here.
+type keySigner struct {
+	key *ecdsa.PrivateKey
+}
+
+func (s keySigner) Address() common.Address {
+	return crypto.PubkeyToAddress(s.key.PublicKey)
+}
+
+func (s keySigner) SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return types.SignTx(tx, types.LatestSignerForChainID(chainID), s.key)
+}
+
+func (s keySigner) SignHash(hash common.Hash) ([]byte, error) {
+	return crypto.Sign(hash.Bytes(), s.key)
+}
+
+func (s keySigner) SignText(data []byte) ([]byte, error) {
+	return crypto.Sign(accounts.TextHash(data), s.key)
+}
+
+// externalSigner forwards signing requests over JSON-RPC to a Clef compatible signer,
+// so the key never leaves it. Clef refuses to sign raw hashes by design.
+type externalSigner struct {
+	clef    *external.ExternalSigner
+	account accounts.Account
+}
+
+func (s externalSigner) Address() common.Address {
+	return s.account.Address
+}
+
+func (s externalSigner) SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return s.clef.SignTx(s.account, tx, chainID)
+}
+
+func (s externalSigner) SignHash(common.Hash) ([]byte, error) {
+	return nil, errRawHashUnsupported
+}
+
+func (s externalSigner) SignText(data []byte) ([]byte, error) {
+	return s.clef.SignText(s.account, data)
+}
+
+// signerBackend returns the configured backend: the signer key if set, otherwise the
+// only one of private_key, keystore, mnemonic and external_signer that is configured.
+func signerBackend() (string, error) {
+	backend := viper.GetString("signer")
+	if backend != "" {
+		switch backend {
+		case signerPrivateKey, signerKeystore, signerMnemonic, signerExternal:
+			return backend, nil
+		default:
+			return "", fmt.Errorf(
+				"unknown signer %q, expected %s, %s, %s or %s",
+				backend, signerPrivateKey, signerKeystore, signerMnemonic, signerExternal,
+			)
+		}
+	}
+
+	var configured []string
+
+	for _, option := range []struct{ backend, key string }{
+		{signerPrivateKey, "private_key"},
+		{signerKeystore, "keystore"},
+		{signerMnemonic, "mnemonic"},
+		{signerExternal, "external_signer"},
+	} {
+		if viper.GetString(option.key) != "" {
+			configured = append(configured, option.backend)
+		}
+	}
+
+	switch len(configured) {
+	case 0:
+		return "", errNoSigner
+	case 1:
+		return configured[0], nil
+	default:
+		return "", fmt.Errorf("several signers are configured (%s), choose one with signer or --signer",
+			strings.Join(configured, ", "))
+	}
+}
+
+// loadedSigner keeps the signer for the rest of the command, so a keystore password is
+// asked for once even when a command both signs a SafeTx and sends a transaction.
+var loadedSigner Signer
+
+func loadSigner() (Signer, error) {
+	if loadedSigner != nil {
+		return loadedSigner, nil
+	}
+
+	backend, err := signerBackend()
+	if err != nil {
+		return nil, err
+	}
+
+	var signer Signer
+
+	switch backend {
+	case signerKeystore:
+		signer, err = loadKeystoreSigner()
+	case signerMnemonic:
+		signer, err = loadMnemonicSigner()
+	case signerExternal:
+		signer, err = loadExternalSigner()
+	default:
+		signer, err = loadPrivateKeySigner()
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	loadedSigner = signer
+
+	return signer, nil
+}
+
+// signerAddress returns the address of the configured signer, or the zero address if
+// none is configured. A keystore is not decrypted, its address is read from the file.
+func signerAddress() (common.Address, error) {
+	backend, err := signerBackend()
+	if errors.Is(err, errNoSigner) {
+		return common.Address{}, nil
+	}
+
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	if backend == signerKeystore {
+		content, err := os.ReadFile(viper.GetString("keystore"))
+		if err != nil {
+			return common.Address{}, err
+		}
+
+		var key struct {
+			Address string `json:"address"`
+		}
+
+		err = json.Unmarshal(content, &key)
+		if err != nil {
+			return common.Address{}, fmt.Errorf("invalid keystore: %w", err)
+		}
+
+		if !common.IsHexAddress(key.Address) {
+			return common.Address{}, fmt.Errorf("keystore has no valid address: %q", key.Address)
+		}
+
+		return common.HexToAddress(key.Address), nil
+	}
+
+	signer, err := loadSigner()
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	return signer.Address(), nil
+}
+
+func loadPrivateKeySigner() (Signer, error) {
+	priv := viper.GetString("private_key")
+	if priv == "" {
+		return nil, errors.New("private_key is not set")
+	}
+
+	key, err := crypto.HexToECDSA(strings.TrimPrefix(priv, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("private_key: %w", err)
+	}
+
+	return keySigner{key: key}, nil
+}
+
+// keystorePassword reads the keystore password from keystore_password_file or
+// keystore_password, or prompts for it when stdin is a terminal.
+func keystorePassword() (string, error) {
+	if path := viper.GetString("keystore_password_file"); path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+
+		return strings.TrimRight(string(content), "\r\n"), nil
+	}
+
+	if viper.IsSet("keystore_password") {
+		return viper.GetString("keystore_password"), nil
+	}
+
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", errors.New("keystore password is not set, use keystore_password_file or keystore_password")
+	}
+
+	fmt.Fprint(os.Stderr, "Keystore password: ")
+
+	password, err := term.ReadPassword(int(os.Stdin.Fd()))
+
+	fmt.Fprintln(os.Stderr)
+
+	if err != nil {
+		return "", err
+	}
+
+	return string(password), nil
+}
+
+func loadKeystoreSigner() (Signer, error) {
+	content, err := os.ReadFile(viper.GetString("keystore"))
+	if err != nil {
+		return nil, err
+	}
+
+	password, err := keystorePassword()
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := keystore.DecryptKey(content, password)
+	if err != nil {
+		return nil, fmt.Errorf("keystore: %w", err)
+	}
+
+	return keySigner{key: key.PrivateKey}, nil
+}
+
+func loadMnemonicSigner() (Signer, error) {
+	mnemonic := strings.Join(strings.Fields(viper.GetString("mnemonic")), " ")
+	if !bip39.IsMnemonicValid(mnemonic) {
+		return nil, errors.New("mnemonic is not a valid BIP-39 phrase")
+	}
+
+	hdPath := viper.GetString("hd_path")
+	if hdPath == "" {
+		hdPath = defaultHDPath
+	}
+
+	path, err := accounts.ParseDerivationPath(hdPath)
+	if err != nil {
+		return nil, fmt.Errorf("hd_path: %w", err)
+	}
+
+	seed := bip39.NewSeed(mnemonic, viper.GetString("mnemonic_passphrase"))
+
+	key, err := deriveHDKey(seed, path)
+	if err != nil {
+		return nil, err
+	}
+
+	return keySigner{key: key}, nil
+}
+
+// loadExternalSigner connects to external_signer and picks signer_address, or the
+// only account the signer manages.
+func loadExternalSigner() (Signer, error) {
+	clef, err := external.NewExternalSigner(viper.GetString("external_signer"))
+	if err != nil {
+		return nil, fmt.Errorf("external signer: %w", err)
+	}
+
+	managed := clef.Accounts()
+
+	if value := viper.GetString("signer_address"); value != "" {
+		address, err := parseAddress(value)
+		if err != nil {
+			return nil, fmt.Errorf("signer_address: %w", err)
+		}
+
+		account := accounts.Account{Address: address} //nolint:exhaustruct
+		if !clef.Contains(account) {
+			return nil, fmt.Errorf("external signer does not manage %s", address.Hex())
+		}
+
+		return externalSigner{clef: clef, account: account}, nil
+	}
+
+	if len(managed) != 1 {
+		return nil, fmt.Errorf("external signer manages %d accounts, choose one with signer_address", len(managed))
+	}
+
+	return externalSigner{clef: clef, account: managed[0]}, nil
+}
+
+func newTransactOpts(provider *ethclient.Client) (*bind.TransactOpts, error) {
+	chainID, err := provider.ChainID(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := loadSigner()
+	if err != nil {
+		return nil, err
+	}
+
+	from := signer.Address()
+
+	return &bind.TransactOpts{ //nolint:exhaustruct
+		From: from,
+		Signer: func(address common.Address, tx *types.Transaction) (*types.Transaction, error) {
+			if address != from {
+				return nil, bind.ErrNotAuthorized
+			}
+
+			return signer.SignTx(tx, chainID)
+		},
+		Context: context.Background(),
+	}, nil
+}
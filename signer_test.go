@@ -0,0 +1,263 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/spf13/viper"
+	"github.com/tyler-smith/go-bip39"
+)
+
+func TestDeriveHDKey(t *testing.T) {
+	t.Parallel()
+
+	// BIP-32 test vector 1.
+	bip32Seed := hexutil.MustDecode("0x000102030405060708090a0b0c0d0e0f")
+
+	// BIP-44 accounts of the Hardhat and Anvil development mnemonic.
+	bip44Seed := bip39.NewSeed("test test test test test test test test test test test junk", "")
+
+	tests := []struct {
+		seed    []byte
+		path    string
+		key     string
+		address string
+	}{
+		{bip32Seed, "m", "0xe8f32e723decf4051aefac8e2c93c9c5b214313817cdb01a1494b917c8436b35", ""},
+		{bip32Seed, "m/0'", "0xedb2e14f9ee77d26dd93b4ecede8d16ed408ce149b6cd80b0715a2d911a0afea", ""},
+		{bip32Seed, "m/0'/1", "0x3c6cb8d0f6a264c91ea8b5030fadaa8e538b020f0a387421a12de9319dc93368", ""},
+		{bip32Seed, "m/0'/1/2'", "0xcbce0d719ecf7431d88e6a89fa1483e02e35092af60c042b1df2ff59fa424dca", ""},
+		{bip32Seed, "m/0'/1/2'/2", "0x0f479245fb19a38a1954c5c7c0ebab2f9bdfd96a17563ef28a6a4b1a2a764ef4", ""},
+		{
+			bip32Seed, "m/0'/1/2'/2/1000000000",
+			"0x471b76e389e528d6de6d816857e012c5455051cad6660850e58372a6c3e6e7c8", "",
+		},
+		{
+			bip44Seed, defaultHDPath,
+			"0xac0974bec39a17e36ba4a6b4d238ff944bacb478cbed5efcae784d7bf4f2ff80",
+			"0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266",
+		},
+		{
+			bip44Seed, "m/44'/60'/0'/0/1",
+			"0x59c6995e998f97a5a0044966f0945389dc9e86dae88c7a8412f4603b6b78690d",
+			"0x70997970C51812dc3A010C7d01b50e0d17dc79C8",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			t.Parallel()
+
+			path := accounts.DerivationPath{}
+
+			if test.path != "m" {
+				var err error
+
+				path, err = accounts.ParseDerivationPath(test.path)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			key, err := deriveHDKey(test.seed, path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := hexutil.Encode(math.PaddedBigBytes(key.D, 32)); got != test.key {
+				t.Errorf("key %s, want %s", got, test.key)
+			}
+
+			if test.address == "" {
+				return
+			}
+
+			if got := crypto.PubkeyToAddress(key.PublicKey); got != common.HexToAddress(test.address) {
+				t.Errorf("address %s, want %s", got.Hex(), test.address)
+			}
+		})
+	}
+}
+
+// clefStub answers the account_ namespace of Clef with a single in-memory key.
+type clefStub struct {
+	key *ecdsa.PrivateKey
+}
+
+type clefSignTxResult struct {
+	Raw hexutil.Bytes      `json:"raw"`
+	Tx  *types.Transaction `json:"tx"`
+}
+
+func (c *clefStub) Version() string {
+	return "6.0.0"
+}
+
+func (c *clefStub) List() []common.Address {
+	return []common.Address{crypto.PubkeyToAddress(c.key.PublicKey)}
+}
+
+// SignData signs text/plain data like Clef, with v of 27 or 28.
+func (c *clefStub) SignData(contentType string, _ common.MixedcaseAddress, data hexutil.Bytes) (hexutil.Bytes, error) {
+	if contentType != accounts.MimetypeTextPlain {
+		return nil, errors.New("unsupported content type " + contentType)
+	}
+
+	signature, err := crypto.Sign(accounts.TextHash(data), c.key)
+	if err != nil {
+		return nil, err
+	}
+
+	signature[64] += 27
+
+	return signature, nil
+}
+
+func (c *clefStub) SignTransaction(args apitypes.SendTxArgs) (*clefSignTxResult, error) {
+	tx, err := args.ToTransaction()
+	if err != nil {
+		return nil, err
+	}
+
+	signed, err := types.SignTx(tx, types.LatestSignerForChainID((*big.Int)(args.ChainID)), c.key)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := signed.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	return &clefSignTxResult{Raw: raw, Tx: signed}, nil
+}
+
+func startClefStub(t *testing.T) common.Address {
+	t.Helper()
+
+	key, err := crypto.HexToECDSA("59c6995e998f97a5a0044966f0945389dc9e86dae88c7a8412f4603b6b78690d")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := rpc.NewServer()
+
+	err = server.RegisterName("account", &clefStub{key: key})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	httpServer := httptest.NewServer(server)
+
+	t.Cleanup(func() {
+		httpServer.Close()
+		server.Stop()
+	})
+
+	viper.Set("external_signer", httpServer.URL)
+	viper.Set("signer_address", "")
+
+	t.Cleanup(func() {
+		viper.Set("external_signer", "")
+		viper.Set("signer_address", "")
+	})
+
+	return crypto.PubkeyToAddress(key.PublicKey)
+}
+
+func TestExternalSigner(t *testing.T) {
+	address := startClefStub(t)
+
+	signer, err := loadExternalSigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if signer.Address() != address {
+		t.Fatalf("account %s, want the only managed account %s", signer.Address().Hex(), address.Hex())
+	}
+
+	t.Run("SignText", func(t *testing.T) {
+		text := []byte("multisig")
+
+		signature, err := signer.SignText(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if signature[64] > 1 {
+			t.Fatalf("v %d, want 0 or 1", signature[64])
+		}
+
+		recovered, err := crypto.SigToPub(accounts.TextHash(text), signature)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if crypto.PubkeyToAddress(*recovered) != address {
+			t.Fatalf("signature recovers to %s, want %s", crypto.PubkeyToAddress(*recovered).Hex(), address.Hex())
+		}
+	})
+
+	t.Run("SignTx", func(t *testing.T) {
+		chainID := big.NewInt(11155111)
+		to := common.HexToAddress("0x70997970C51812dc3A010C7d01b50e0d17dc79C8")
+
+		tx := types.NewTx(&types.DynamicFeeTx{ //nolint:exhaustruct
+			ChainID:   chainID,
+			Nonce:     3,
+			GasTipCap: big.NewInt(1_000_000_000),
+			GasFeeCap: big.NewInt(30_000_000_000),
+			Gas:       21000,
+			To:        &to,
+			Value:     big.NewInt(1),
+		})
+
+		signed, err := signer.SignTx(tx, chainID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sender, err := types.Sender(types.LatestSignerForChainID(chainID), signed)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if sender != address {
+			t.Fatalf("sender %s, want %s", sender.Hex(), address.Hex())
+		}
+
+		if signed.Nonce() != tx.Nonce() || signed.GasFeeCap().Cmp(tx.GasFeeCap()) != 0 || *signed.To() != to {
+			t.Fatal("the signer changed the transaction")
+		}
+	})
+
+	t.Run("SignHash", func(t *testing.T) {
+		_, err := signer.SignHash(common.Hash{})
+		if !errors.Is(err, errRawHashUnsupported) {
+			t.Fatalf("error %v, want %v", err, errRawHashUnsupported)
+		}
+	})
+}
+
+func TestExternalSignerUnmanagedAccount(t *testing.T) {
+	startClefStub(t)
+
+	viper.Set("signer_address", "0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266")
+
+	_, err := loadExternalSigner()
+	if err == nil {
+		t.Fatal("expected an error for an account the signer does not manage")
+	}
+}
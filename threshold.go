@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/timofvy/multisig/abi/safe_abi"
+)
+
+func thresholdCommand() *command {
+	return &command{
+		name:        "threshold",
+		args:        "",
+		description: "Inspect and change the Safe threshold",
+		run:         nil,
+		subcommands: []*command{
+			thresholdGetCommand(),
+			thresholdSetCommand(),
+		},
+	}
+}
+
+func thresholdGetCommand() *command {
+	return &command{
+		name:        "get",
+		args:        "<safe>",
+		description: "Print the current threshold and owner count",
+		run: func(fs *flag.FlagSet, args []string) error {
+			positional, err := parseArgs(fs, args, 1)
+			if err != nil {
+				return err
+			}
+
+			_, caller, _, err := openSafe(positional[0])
+			if err != nil {
+				return err
+			}
+
+			opts := &bind.CallOpts{Context: context.Background()} //nolint:exhaustruct
+
+			threshold, err := caller.GetThreshold(opts)
+			if err != nil {
+				return err
+			}
+
+			owners, err := caller.GetOwners(opts)
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("%s of %d\n", threshold.String(), len(owners))
+
+			return nil
+		},
+		subcommands: nil,
+	}
+}
+
+func thresholdSetCommand() *command {
+	return &command{
+		name:        "set",
+		args:        "<safe> <n>",
+		description: "Build a changeThreshold Safe transaction",
+		run: func(fs *flag.FlagSet, args []string) error {
+			selfTx := addSelfTxFlags(fs)
+
+			positional, err := parseArgs(fs, args, 2)
+			if err != nil {
+				return err
+			}
+
+			threshold, err := strconv.Atoi(positional[1])
+			if err != nil {
+				return fmt.Errorf("invalid threshold %q", positional[1])
+			}
+
+			provider, caller, safe, err := openSafe(positional[0])
+			if err != nil {
+				return err
+			}
+
+			owners, current, err := loadOwners(caller)
+			if err != nil {
+				return err
+			}
+
+			err = checkNewThreshold(threshold, len(owners))
+			if err != nil {
+				return err
+			}
+
+			if threshold == current {
+				return fmt.Errorf("threshold is already %d", threshold)
+			}
+
+			if threshold == 1 && len(owners) > 1 {
+				log.Printf("Warning: lowering the threshold to 1 of %d lets any single owner control the Safe", len(owners))
+			}
+
+			data, err := packSafeCall("changeThreshold", big.NewInt(int64(threshold)))
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Change threshold from %d to %d of %d\n", current, threshold, len(owners))
+
+			receipt, err := selfTx.run(provider, caller, safe, data)
+			if err != nil || receipt == nil {
+				return err
+			}
+
+			return confirmChangedThreshold(provider, safe, receipt, threshold)
+		},
+		subcommands: nil,
+	}
+}
+
+func confirmChangedThreshold(
+	provider *ethclient.Client,
+	safe common.Address,
+	receipt *types.Receipt,
+	threshold int,
+) error {
+	filterer, err := safe_abi.NewSafeAbiFilterer(safe, provider)
+	if err != nil {
+		return err
+	}
+
+	for _, receiptLog := range receipt.Logs {
+		if receiptLog.Address != safe {
+			continue
+		}
+
+		event, err := filterer.ParseChangedThreshold(*receiptLog)
+		if err != nil {
+			continue
+		}
+
+		if event.Threshold.Cmp(big.NewInt(int64(threshold))) != 0 {
+			return fmt.Errorf("ChangedThreshold reports %s, expected %d", event.Threshold, threshold)
+		}
+
+		log.Println("ChangedThreshold: ", event.Threshold.String())
+
+		return nil
+	}
+
+	return fmt.Errorf("no ChangedThreshold event in transaction %s", receipt.TxHash.Hex())
+}
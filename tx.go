@@ -0,0 +1,477 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/spf13/viper"
+	"github.com/timofvy/multisig/abi/safe_abi"
+)
+
+// pendingSafeTx is a Safe transaction bound to its Safe and chain together with its EIP-712 hash.
+type pendingSafeTx struct {
+	safe    common.Address
+	chainID *big.Int
+	tx      safeTx
+	hash    common.Hash
+}
+
+func txCommand() *command {
+	return &command{
+		name:        "tx",
+		args:        "",
+		description: "Propose, sign and execute Safe transactions",
+		run:         nil,
+		subcommands: []*command{
+			txProposeCommand(),
+			txSignCommand(),
+			txExecCommand(),
+			txCreateCommand(),
+			txInspectCommand(),
+			txAddSigCommand(),
+			txMergeCommand(),
+			txExecFileCommand(),
+			txApproveHashCommand(),
+		},
+	}
+}
+
+// loadSafeTx parses the Safe address and the transaction flags shared by the tx commands and computes
+// the SafeTx hash locally. Online the hash is cross-checked against the Safe contract, offline no RPC
+// is used at all: the chain ID comes from --chain-id and the nonce must be given explicitly.
+func loadSafeTx(
+	safeArg string,
+	txFlags safeTxFlags,
+	offline bool,
+) (pendingSafeTx, error) {
+	if offline {
+		safe, err := parseAddress(safeArg)
+		if err != nil {
+			return pendingSafeTx{}, err
+		}
+
+		chainID := viper.GetUint64("chain_id")
+		if chainID == 0 {
+			return pendingSafeTx{}, errors.New("--chain-id is required offline")
+		}
+
+		tx, err := txFlags.build(nil)
+		if err != nil {
+			return pendingSafeTx{}, err
+		}
+
+		return newPendingSafeTx(new(big.Int).SetUint64(chainID), safe, tx), nil
+	}
+
+	provider, caller, safe, err := openSafe(safeArg)
+	if err != nil {
+		return pendingSafeTx{}, err
+	}
+
+	chainID, err := provider.ChainID(context.Background())
+	if err != nil {
+		return pendingSafeTx{}, err
+	}
+
+	tx, err := txFlags.build(caller)
+	if err != nil {
+		return pendingSafeTx{}, err
+	}
+
+	hash, err := crossCheckSafeTxHash(caller, chainID, safe, tx)
+	if err != nil {
+		return pendingSafeTx{}, err
+	}
+
+	return pendingSafeTx{safe: safe, chainID: chainID, tx: tx, hash: hash}, nil
+}
+
+func newPendingSafeTx(chainID *big.Int, safe common.Address, tx safeTx) pendingSafeTx {
+	return pendingSafeTx{
+		safe:    safe,
+		chainID: chainID,
+		tx:      tx,
+		hash:    computeSafeTxHash(chainID, safe, tx),
+	}
+}
+
+func txProposeCommand() *command {
+	return &command{
+		name:        "propose",
+		args:        "<safe>",
+		description: "Build a Safe transaction and print its hash",
+		run: func(fs *flag.FlagSet, args []string) error {
+			txFlags := addSafeTxFlags(fs)
+			offline := fs.Bool("offline", false, "Compute the hash without RPC, requires --nonce and --chain-id")
+
+			positional, err := parseArgs(fs, args, 1)
+			if err != nil {
+				return err
+			}
+
+			pending, err := loadSafeTx(positional[0], txFlags, *offline)
+			if err != nil {
+				return err
+			}
+
+			printSafeTx(pending.safe, pending.tx, pending.hash)
+
+			return nil
+		},
+		subcommands: nil,
+	}
+}
+
+func txSignCommand() *command {
+	return &command{
+		name:        "sign",
+		args:        "<safe>",
+		description: "Sign a Safe transaction hash with the configured signer",
+		run: func(fs *flag.FlagSet, args []string) error {
+			txFlags := addSafeTxFlags(fs)
+			offline := fs.Bool("offline", false, "Sign without RPC, requires --nonce and --chain-id")
+			ethSign := fs.Bool("eth-sign", false, "Produce an eth_sign style signature instead of a plain EIP-712 one")
+
+			positional, err := parseArgs(fs, args, 1)
+			if err != nil {
+				return err
+			}
+
+			pending, err := loadSafeTx(positional[0], txFlags, *offline)
+			if err != nil {
+				return err
+			}
+
+			signer, err := loadSigner()
+			if err != nil {
+				return err
+			}
+
+			signature, err := signSafeTxHash(signer, pending.hash, *ethSign)
+			if err != nil {
+				return err
+			}
+
+			printSafeTx(pending.safe, pending.tx, pending.hash)
+			fmt.Println("Signer:         ", signature.signer.Hex())
+			fmt.Println("Type:           ", signature.kind)
+			fmt.Println("Signature:      ", hexutil.Encode(signature.signature))
+
+			return nil
+		},
+		subcommands: nil,
+	}
+}
+
+func txExecCommand() *command {
+	return &command{
+		name:        "exec",
+		args:        "<safe>",
+		description: "Check the collected signatures and execute a Safe transaction",
+		run: func(fs *flag.FlagSet, args []string) error {
+			txFlags := addSafeTxFlags(fs)
+			signatureFlags := addSignatureFlags(fs)
+			sign := fs.Bool("sign", false, "Add a signature of the configured signer")
+			confirmations := fs.Uint64("confirmations", 1, "Number of confirmations to wait for")
+			timeout := fs.Duration("timeout", 5*time.Minute, "How long to wait for the transaction to be confirmed")
+			feeFlags := addFeeFlags(fs)
+
+			positional, err := parseArgs(fs, args, 1)
+			if err != nil {
+				return err
+			}
+
+			fees, err := feeFlags.settings()
+			if err != nil {
+				return err
+			}
+
+			pending, err := loadSafeTx(positional[0], txFlags, false)
+			if err != nil {
+				return err
+			}
+
+			signatures, err := signatureFlags.collect(pending.hash)
+			if err != nil {
+				return err
+			}
+
+			if *sign {
+				signer, err := loadSigner()
+				if err != nil {
+					return err
+				}
+
+				signature, err := signSafeTxHash(signer, pending.hash, false)
+				if err != nil {
+					return err
+				}
+
+				signatures = append(signatures, signature)
+			}
+
+			printSafeTx(pending.safe, pending.tx, pending.hash)
+
+			_, err = execSafeTx(pending, signatures, fees, *confirmations, *timeout)
+
+			return err
+		},
+		subcommands: nil,
+	}
+}
+
+// execSafeTx pre-checks the signatures, submits ExecTransaction and reports the outcome of the
+// inner Safe transaction from the ExecutionSuccess or ExecutionFailure event.
+func execSafeTx(
+	pending pendingSafeTx,
+	signatures []ownerSignature,
+	fees feeSettings,
+	confirmations uint64,
+	timeout time.Duration,
+) (*types.Receipt, error) {
+	provider, err := getProvider()
+	if err != nil {
+		return nil, err
+	}
+
+	safeContract, err := safe_abi.NewSafeAbi(pending.safe, provider)
+	if err != nil {
+		return nil, err
+	}
+
+	packed, err := encodeSignatures(signatures)
+	if err != nil {
+		return nil, err
+	}
+
+	trOpts, err := newTransactOpts(provider)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkSafeSignatures(
+		&safeContract.SafeAbiCaller,
+		trOpts.From,
+		pending.hash,
+		encodeSafeTxData(pending.chainID, pending.safe, pending.tx),
+		signatures,
+		packed,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Println("Signatures verified: ", len(signatures))
+
+	tx := pending.tx
+
+	transaction, err := fees.transact(provider, trOpts, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return safeContract.ExecTransaction(
+			opts,
+			tx.to,
+			tx.value,
+			tx.data,
+			tx.operation,
+			tx.safeTxGas,
+			tx.baseGas,
+			tx.gasPrice,
+			tx.gasToken,
+			tx.refundReceiver,
+			packed,
+		)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("exec transaction: %s", decodeRevert(err))
+	}
+
+	log.Println("Transaction sent: ", transaction.Hash().Hex())
+
+	receipt, err := waitForReceipt(provider, transaction, confirmations, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	reportReceipt(receipt)
+
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return nil, fmt.Errorf("transaction %s reverted", transaction.Hash().Hex())
+	}
+
+	return receipt, reportExecution(provider, pending.safe, receipt)
+}
+
+func reportExecution(provider *ethclient.Client, safe common.Address, receipt *types.Receipt) error {
+	filterer, err := safe_abi.NewSafeAbiFilterer(safe, provider)
+	if err != nil {
+		return err
+	}
+
+	for _, receiptLog := range receipt.Logs {
+		if receiptLog.Address != safe {
+			continue
+		}
+
+		success, err := filterer.ParseExecutionSuccess(*receiptLog)
+		if err == nil {
+			log.Printf("ExecutionSuccess: SafeTxHash %s, payment %s", common.Hash(success.TxHash).Hex(), success.Payment)
+
+			return nil
+		}
+
+		failure, err := filterer.ParseExecutionFailure(*receiptLog)
+		if err == nil {
+			return fmt.Errorf(
+				"ExecutionFailure: SafeTxHash %s, payment %s",
+				common.Hash(failure.TxHash).Hex(),
+				failure.Payment,
+			)
+		}
+
+		hash, payment, ok := legacyEventData(*receiptLog, "ExecutionSuccess(bytes32,uint256)")
+		if ok {
+			log.Printf("ExecutionSuccess: SafeTxHash %s, payment %s", hash.Hex(), payment.Big())
+
+			return nil
+		}
+
+		hash, payment, ok = legacyEventData(*receiptLog, "ExecutionFailure(bytes32,uint256)")
+		if ok {
+			return fmt.Errorf("ExecutionFailure: SafeTxHash %s, payment %s", hash.Hex(), payment.Big())
+		}
+	}
+
+	return fmt.Errorf("no ExecutionSuccess or ExecutionFailure event in transaction %s", receipt.TxHash.Hex())
+}
+
+func txApproveHashCommand() *command {
+	return &command{
+		name:        "approve-hash",
+		args:        "<safe> | --file <file>",
+		description: "Approve a Safe transaction hash on-chain from the configured owner",
+		run: func(fs *flag.FlagSet, args []string) error {
+			txFlags := addSafeTxFlags(fs)
+			file := fs.String("file", "", "Approve the transaction from this file instead of the flags")
+			confirmations := fs.Uint64("confirmations", 1, "Number of confirmations to wait for")
+			timeout := fs.Duration("timeout", 5*time.Minute, "How long to wait for the transaction to be confirmed")
+			feeFlags := addFeeFlags(fs)
+
+			positional, err := parseVarArgs(fs, args, 0)
+			if err != nil {
+				return err
+			}
+
+			fees, err := feeFlags.settings()
+			if err != nil {
+				return err
+			}
+
+			var pending pendingSafeTx
+
+			switch {
+			case *file != "" && len(positional) == 0:
+				pending, _, err = readSafeTxFile(*file)
+			case *file == "" && len(positional) == 1:
+				pending, err = loadSafeTx(positional[0], txFlags, false)
+			default:
+				return errUsage
+			}
+			if err != nil {
+				return err
+			}
+
+			printSafeTx(pending.safe, pending.tx, pending.hash)
+
+			return approveSafeTxHash(pending, fees, *confirmations, *timeout)
+		},
+		subcommands: nil,
+	}
+}
+
+func approveSafeTxHash(
+	pending pendingSafeTx,
+	fees feeSettings,
+	confirmations uint64,
+	timeout time.Duration,
+) error {
+	provider, err := getProvider()
+	if err != nil {
+		return err
+	}
+
+	safeContract, err := safe_abi.NewSafeAbi(pending.safe, provider)
+	if err != nil {
+		return err
+	}
+
+	trOpts, err := newTransactOpts(provider)
+	if err != nil {
+		return err
+	}
+
+	opts := &bind.CallOpts{Context: context.Background()} //nolint:exhaustruct
+
+	isOwner, err := safeContract.IsOwner(opts, trOpts.From)
+	if err != nil {
+		return err
+	}
+
+	if !isOwner {
+		return fmt.Errorf("signer %s is not an owner", trOpts.From.Hex())
+	}
+
+	approved, err := safeContract.ApprovedHashes(opts, trOpts.From, pending.hash)
+	if err != nil {
+		return err
+	}
+
+	if approved.Sign() != 0 {
+		log.Println("Hash is already approved by ", trOpts.From.Hex())
+
+		return nil
+	}
+
+	transaction, err := fees.transact(provider, trOpts, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return safeContract.ApproveHash(opts, pending.hash)
+	})
+	if err != nil {
+		return fmt.Errorf("approve hash: %s", decodeRevert(err))
+	}
+
+	log.Println("Transaction sent: ", transaction.Hash().Hex())
+
+	receipt, err := waitForReceipt(provider, transaction, confirmations, timeout)
+	if err != nil {
+		return err
+	}
+
+	reportReceipt(receipt)
+
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return fmt.Errorf("transaction %s reverted", transaction.Hash().Hex())
+	}
+
+	for _, receiptLog := range receipt.Logs {
+		if receiptLog.Address != pending.safe {
+			continue
+		}
+
+		event, err := safeContract.ParseApproveHash(*receiptLog)
+		if err == nil {
+			log.Printf("ApproveHash: %s by %s", common.Hash(event.ApprovedHash).Hex(), event.Owner.Hex())
+
+			return nil
+		}
+	}
+
+	return fmt.Errorf("no ApproveHash event in transaction %s", transaction.Hash().Hex())
+}
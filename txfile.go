@@ -0,0 +1,418 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// safeTxFile is the portable JSON form of a pending Safe transaction that is passed between owners.
+type safeTxFile struct {
+	Safe           string             `json:"safe"`
+	ChainID        string             `json:"chainId"`
+	To             string             `json:"to"`
+	Value          string             `json:"value"`
+	Data           string             `json:"data"`
+	Operation      uint8              `json:"operation"`
+	SafeTxGas      string             `json:"safeTxGas"`
+	BaseGas        string             `json:"baseGas"`
+	GasPrice       string             `json:"gasPrice"`
+	GasToken       string             `json:"gasToken"`
+	RefundReceiver string             `json:"refundReceiver"`
+	Nonce          string             `json:"nonce"`
+	SafeTxHash     string             `json:"safeTxHash"`
+	Signatures     []safeTxFileSigner `json:"signatures"`
+}
+
+type safeTxFileSigner struct {
+	Signer    string `json:"signer"`
+	Type      string `json:"type"`
+	Signature string `json:"signature"`
+}
+
+func newSafeTxFile(pending pendingSafeTx, signatures []ownerSignature) safeTxFile {
+	file := safeTxFile{
+		Safe:           pending.safe.Hex(),
+		ChainID:        pending.chainID.String(),
+		To:             pending.tx.to.Hex(),
+		Value:          pending.tx.value.String(),
+		Data:           hexutil.Encode(pending.tx.data),
+		Operation:      pending.tx.operation,
+		SafeTxGas:      pending.tx.safeTxGas.String(),
+		BaseGas:        pending.tx.baseGas.String(),
+		GasPrice:       pending.tx.gasPrice.String(),
+		GasToken:       pending.tx.gasToken.Hex(),
+		RefundReceiver: pending.tx.refundReceiver.Hex(),
+		Nonce:          pending.tx.nonce.String(),
+		SafeTxHash:     pending.hash.Hex(),
+		Signatures:     make([]safeTxFileSigner, 0, len(signatures)),
+	}
+
+	for _, signature := range signatures {
+		file.Signatures = append(file.Signatures, safeTxFileSigner{
+			Signer:    signature.signer.Hex(),
+			Type:      signature.kind,
+			Signature: hexutil.Encode(signature.signature),
+		})
+	}
+
+	return file
+}
+
+// decode rebuilds the transaction from the file fields and checks that the recorded hash and every
+// signature match them, so an edited file is rejected instead of being signed or executed.
+func (f safeTxFile) decode() (pendingSafeTx, []ownerSignature, error) {
+	safe, err := parseAddress(f.Safe)
+	if err != nil {
+		return pendingSafeTx{}, nil, fmt.Errorf("safe: %w", err)
+	}
+
+	chainID, err := parseUint256("chainId", f.ChainID)
+	if err != nil {
+		return pendingSafeTx{}, nil, err
+	}
+
+	var tx safeTx
+
+	tx.to, err = parseAddress(f.To)
+	if err != nil {
+		return pendingSafeTx{}, nil, fmt.Errorf("to: %w", err)
+	}
+
+	tx.data, err = hexutil.Decode(f.Data)
+	if err != nil {
+		return pendingSafeTx{}, nil, fmt.Errorf("data: %w", err)
+	}
+
+	if f.Operation > operationDelegateCall {
+		return pendingSafeTx{}, nil, fmt.Errorf("operation: must be 0 or 1, got %d", f.Operation)
+	}
+
+	tx.operation = f.Operation
+
+	numbers := []struct {
+		name   string
+		value  string
+		target **big.Int
+	}{
+		{"value", f.Value, &tx.value},
+		{"safeTxGas", f.SafeTxGas, &tx.safeTxGas},
+		{"baseGas", f.BaseGas, &tx.baseGas},
+		{"gasPrice", f.GasPrice, &tx.gasPrice},
+		{"nonce", f.Nonce, &tx.nonce},
+	}
+	for _, number := range numbers {
+		*number.target, err = parseUint256(number.name, number.value)
+		if err != nil {
+			return pendingSafeTx{}, nil, err
+		}
+	}
+
+	tx.gasToken, err = parseAddress(f.GasToken)
+	if err != nil {
+		return pendingSafeTx{}, nil, fmt.Errorf("gasToken: %w", err)
+	}
+
+	tx.refundReceiver, err = parseAddress(f.RefundReceiver)
+	if err != nil {
+		return pendingSafeTx{}, nil, fmt.Errorf("refundReceiver: %w", err)
+	}
+
+	pending := newPendingSafeTx(chainID, safe, tx)
+	if pending.hash.Hex() != f.SafeTxHash {
+		return pendingSafeTx{}, nil, fmt.Errorf(
+			"safeTxHash %s does not match the transaction fields, expected %s",
+			f.SafeTxHash,
+			pending.hash.Hex(),
+		)
+	}
+
+	signatures := make([]ownerSignature, 0, len(f.Signatures))
+	for _, item := range f.Signatures {
+		signer, err := parseAddress(item.Signer)
+		if err != nil {
+			return pendingSafeTx{}, nil, fmt.Errorf("signer: %w", err)
+		}
+
+		raw, err := hexutil.Decode(item.Signature)
+		if err != nil {
+			return pendingSafeTx{}, nil, fmt.Errorf("signature of %s: %w", item.Signer, err)
+		}
+
+		signature := ownerSignature{signer: signer, kind: item.Type, signature: raw}
+
+		err = signature.validate(pending.hash)
+		if err != nil {
+			return pendingSafeTx{}, nil, err
+		}
+
+		signatures = append(signatures, signature)
+	}
+
+	return pending, signatures, nil
+}
+
+func readSafeTxFile(path string) (pendingSafeTx, []ownerSignature, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return pendingSafeTx{}, nil, err
+	}
+
+	var file safeTxFile
+
+	err = json.Unmarshal(content, &file)
+	if err != nil {
+		return pendingSafeTx{}, nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	pending, signatures, err := file.decode()
+	if err != nil {
+		return pendingSafeTx{}, nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return pending, signatures, nil
+}
+
+func writeSafeTxFile(path string, pending pendingSafeTx, signatures []ownerSignature) error {
+	content, err := json.MarshalIndent(newSafeTxFile(pending, signatures), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(content, '\n'), 0o600)
+}
+
+// mergeSignatures adds signatures whose signer is not present yet.
+func mergeSignatures(signatures []ownerSignature, more ...ownerSignature) []ownerSignature {
+	for _, signature := range more {
+		found := false
+		for _, existing := range signatures {
+			if existing.signer == signature.signer {
+				found = true
+
+				break
+			}
+		}
+
+		if !found {
+			signatures = append(signatures, signature)
+		}
+	}
+
+	return signatures
+}
+
+func txCreateCommand() *command {
+	return &command{
+		name:        "create",
+		args:        "<safe>",
+		description: "Write a Safe transaction to a JSON file for offline signing",
+		run: func(fs *flag.FlagSet, args []string) error {
+			txFlags := addSafeTxFlags(fs)
+			offline := fs.Bool("offline", false, "Create without RPC, requires --nonce and --chain-id")
+			out := fs.String("out", "", "Path of the transaction file")
+
+			positional, err := parseArgs(fs, args, 1)
+			if err != nil {
+				return err
+			}
+
+			if *out == "" {
+				return errors.New("--out is required")
+			}
+
+			pending, err := loadSafeTx(positional[0], txFlags, *offline)
+			if err != nil {
+				return err
+			}
+
+			printSafeTx(pending.safe, pending.tx, pending.hash)
+
+			return writeSafeTxFile(*out, pending, nil)
+		},
+		subcommands: nil,
+	}
+}
+
+func txInspectCommand() *command {
+	return &command{
+		name:        "inspect",
+		args:        "<file>",
+		description: "Validate a transaction file and print its contents",
+		run: func(fs *flag.FlagSet, args []string) error {
+			positional, err := parseArgs(fs, args, 1)
+			if err != nil {
+				return err
+			}
+
+			pending, signatures, err := readSafeTxFile(positional[0])
+			if err != nil {
+				return err
+			}
+
+			printSafeTx(pending.safe, pending.tx, pending.hash)
+			fmt.Println("Chain ID:       ", pending.chainID.String())
+			fmt.Println("Signatures:     ", len(signatures))
+
+			for _, signature := range signatures {
+				fmt.Printf("   %s %s %s\n", signature.signer.Hex(), signature.kind, hexutil.Encode(signature.signature))
+			}
+
+			return nil
+		},
+		subcommands: nil,
+	}
+}
+
+func txAddSigCommand() *command {
+	return &command{
+		name:        "add-sig",
+		args:        "<file>",
+		description: "Sign a transaction file with the configured signer or add given signatures",
+		run: func(fs *flag.FlagSet, args []string) error {
+			signatureFlags := addSignatureFlags(fs)
+			ethSign := fs.Bool("eth-sign", false, "Produce an eth_sign style signature instead of a plain EIP-712 one")
+
+			positional, err := parseArgs(fs, args, 1)
+			if err != nil {
+				return err
+			}
+
+			pending, signatures, err := readSafeTxFile(positional[0])
+			if err != nil {
+				return err
+			}
+
+			added, err := signatureFlags.collect(pending.hash)
+			if err != nil {
+				return err
+			}
+
+			if len(added) == 0 {
+				signer, err := loadSigner()
+				if err != nil {
+					return err
+				}
+
+				signature, err := signSafeTxHash(signer, pending.hash, *ethSign)
+				if err != nil {
+					return err
+				}
+
+				added = append(added, signature)
+			}
+
+			for _, signature := range added {
+				fmt.Println("Signer:         ", signature.signer.Hex(), signature.kind)
+			}
+
+			return writeSafeTxFile(positional[0], pending, mergeSignatures(signatures, added...))
+		},
+		subcommands: nil,
+	}
+}
+
+func txMergeCommand() *command {
+	return &command{
+		name:        "merge",
+		args:        "<file> <file>...",
+		description: "Merge the signatures of several copies of the same transaction file",
+		run: func(fs *flag.FlagSet, args []string) error {
+			out := fs.String("out", "", "Path of the merged file, defaults to the first file")
+
+			files, err := parseVarArgs(fs, args, 2)
+			if err != nil {
+				return err
+			}
+
+			pending, signatures, err := readSafeTxFile(files[0])
+			if err != nil {
+				return err
+			}
+
+			for _, path := range files[1:] {
+				other, more, err := readSafeTxFile(path)
+				if err != nil {
+					return err
+				}
+
+				if other.hash != pending.hash {
+					return fmt.Errorf("%s holds transaction %s, expected %s", path, other.hash.Hex(), pending.hash.Hex())
+				}
+
+				signatures = mergeSignatures(signatures, more...)
+			}
+
+			target := *out
+			if target == "" {
+				target = files[0]
+			}
+
+			fmt.Println("Signatures:     ", len(signatures))
+
+			return writeSafeTxFile(target, pending, signatures)
+		},
+		subcommands: nil,
+	}
+}
+
+func txExecFileCommand() *command {
+	return &command{
+		name:        "exec-file",
+		args:        "<file>",
+		description: "Execute a fully signed transaction file",
+		run: func(fs *flag.FlagSet, args []string) error {
+			confirmations := fs.Uint64("confirmations", 1, "Number of confirmations to wait for")
+			timeout := fs.Duration("timeout", 5*time.Minute, "How long to wait for the transaction to be confirmed")
+			feeFlags := addFeeFlags(fs)
+
+			positional, err := parseArgs(fs, args, 1)
+			if err != nil {
+				return err
+			}
+
+			fees, err := feeFlags.settings()
+			if err != nil {
+				return err
+			}
+
+			pending, signatures, err := readSafeTxFile(positional[0])
+			if err != nil {
+				return err
+			}
+
+			provider, caller, _, err := openSafe(pending.safe.Hex())
+			if err != nil {
+				return err
+			}
+
+			chainID, err := provider.ChainID(context.Background())
+			if err != nil {
+				return err
+			}
+
+			if chainID.Cmp(pending.chainID) != 0 {
+				return fmt.Errorf("transaction file is for chain %s, rpc is on chain %s", pending.chainID, chainID)
+			}
+
+			_, err = crossCheckSafeTxHash(caller, chainID, pending.safe, pending.tx)
+			if err != nil {
+				return err
+			}
+
+			printSafeTx(pending.safe, pending.tx, pending.hash)
+
+			_, err = execSafeTx(pending, signatures, fees, *confirmations, *timeout)
+
+			return err
+		},
+		subcommands: nil,
+	}
+}
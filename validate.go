@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// sentinelAddress marks the head of the owner and module linked lists in OwnerManager and
+// ModuleManager, it can never be an owner or a module.
+const sentinelAddress = "0x0000000000000000000000000000000000000001"
+
+// parseAddress accepts only 0x-prefixed 20 byte hex addresses with a valid EIP-55 checksum,
+// so typos are rejected instead of being turned into a different address.
+func parseAddress(value string) (common.Address, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return common.Address{}, errors.New("empty address")
+	}
+
+	if !strings.HasPrefix(value, "0x") || !common.IsHexAddress(value) {
+		return common.Address{}, fmt.Errorf("%q is not a 0x-prefixed 20 byte hex address", value)
+	}
+
+	address := common.HexToAddress(value)
+	if value != address.Hex() {
+		return common.Address{}, fmt.Errorf("%q has an invalid EIP-55 checksum, expected %s", value, address.Hex())
+	}
+
+	return address, nil
+}
+
+func parseOwners(value string) ([]common.Address, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, errors.New("owners must be greater than 0")
+	}
+
+	var owners []common.Address
+	for i, item := range strings.Split(value, ",") {
+		owner, err := parseAddress(item)
+		if err != nil {
+			return nil, fmt.Errorf("owner #%d: %w", i+1, err)
+		}
+
+		owners = append(owners, owner)
+	}
+
+	return owners, nil
+}
+
+func validateOwners(owners []common.Address, threshold int) error {
+	if len(owners) == 0 {
+		return errors.New("owners must be greater than 0")
+	}
+
+	if threshold < 1 {
+		return errors.New("threshold must be greater than 0")
+	}
+
+	if threshold > len(owners) {
+		return fmt.Errorf("threshold %d exceeds the number of owners %d", threshold, len(owners))
+	}
+
+	seen := make(map[common.Address]int, len(owners))
+	for i, owner := range owners {
+		switch owner {
+		case common.HexToAddress(zeroAddress):
+			return fmt.Errorf("owner #%d is the zero address", i+1)
+		case common.HexToAddress(sentinelAddress):
+			return fmt.Errorf("owner #%d is the sentinel address %s", i+1, sentinelAddress)
+		}
+
+		if first, ok := seen[owner]; ok {
+			return fmt.Errorf("owner #%d %s duplicates owner #%d", i+1, owner.Hex(), first)
+		}
+
+		seen[owner] = i + 1
+	}
+
+	return nil
+}
+
+// warnContractOwners logs owners that have code: such owners can only sign through EIP-1271
+// or approved hashes, which is easy to overlook when the address was expected to be an EOA.
+func warnContractOwners(provider *ethclient.Client, owners []common.Address) error {
+	for _, owner := range owners {
+		code, err := provider.CodeAt(context.Background(), owner, nil)
+		if err != nil {
+			return err
+		}
+
+		if len(code) > 0 {
+			log.Println("Warning: owner", owner.Hex(), "is a contract, not an EOA")
+		}
+	}
+
+	return nil
+}
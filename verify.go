@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/timofvy/multisig/abi/safe_abi"
+)
+
+// keccak256("fallback_manager.handler.address"), see FallbackManager.sol.
+var fallbackHandlerSlot = common.HexToHash("0x6c9a6c4a39284e37ed1cf53d337577d14212a4870fb976a4366c693b939918d5")
+
+type expectedSafe struct {
+	owners          []common.Address
+	threshold       int
+	singleton       common.Address
+	fallbackHandler common.Address
+}
+
+type verificationCheck struct {
+	name     string
+	expected string
+	actual   string
+	ok       bool
+}
+
+func sameOwners(expected, actual []common.Address) bool {
+	if len(expected) != len(actual) {
+		return false
+	}
+
+	seen := make(map[common.Address]bool, len(actual))
+	for _, owner := range actual {
+		seen[owner] = true
+	}
+
+	for _, owner := range expected {
+		if !seen[owner] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func verifySafe(
+	provider *ethclient.Client,
+	safe common.Address,
+	expected expectedSafe,
+) ([]verificationCheck, error) {
+	caller, err := safe_abi.NewSafeAbiCaller(safe, provider)
+	if err != nil {
+		return nil, err
+	}
+
+	singletonCaller, err := safe_abi.NewSafeAbiCaller(expected.singleton, provider)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &bind.CallOpts{Context: context.Background()} //nolint:exhaustruct
+
+	owners, err := caller.GetOwners(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	threshold, err := caller.GetThreshold(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := caller.VERSION(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	singletonVersion, err := singletonCaller.VERSION(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := caller.Nonce(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	fallbackHandler, err := readStorageAddress(caller, fallbackHandlerSlot)
+	if err != nil {
+		return nil, err
+	}
+
+	singleton, err := readStorageAddress(caller, common.Hash{})
+	if err != nil {
+		return nil, err
+	}
+
+	checks := []verificationCheck{
+		{
+			name:     "owners",
+			expected: fmt.Sprint(expected.owners),
+			actual:   fmt.Sprint(owners),
+			ok:       sameOwners(expected.owners, owners),
+		},
+		{
+			name:     "threshold",
+			expected: fmt.Sprint(expected.threshold),
+			actual:   threshold.String(),
+			ok:       threshold.Cmp(big.NewInt(int64(expected.threshold))) == 0,
+		},
+		{
+			name:     "version",
+			expected: singletonVersion,
+			actual:   version,
+			ok:       version == singletonVersion,
+		},
+		{
+			name:     "nonce",
+			expected: "0",
+			actual:   nonce.String(),
+			ok:       nonce.Sign() == 0,
+		},
+		{
+			name:     "fallback handler",
+			expected: expected.fallbackHandler.Hex(),
+			actual:   fallbackHandler.Hex(),
+			ok:       fallbackHandler == expected.fallbackHandler,
+		},
+		{
+			name:     "singleton",
+			expected: expected.singleton.Hex(),
+			actual:   singleton.Hex(),
+			ok:       singleton == expected.singleton,
+		},
+	}
+
+	return checks, nil
+}
+
+func reportVerification(safe common.Address, checks []verificationCheck) error {
+	log.Println("Verification of Safe ", safe.Hex())
+
+	failed := 0
+	for _, check := range checks {
+		status := "PASS"
+		if !check.ok {
+			status = "FAIL"
+			failed++
+		}
+
+		log.Printf("  [%s] %s: expected %s, got %s", status, check.name, check.expected, check.actual)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("verification failed: %d of %d checks did not pass", failed, len(checks))
+	}
+
+	log.Println("Verification passed")
+
+	return nil
+}
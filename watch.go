@@ -0,0 +1,587 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/timofvy/multisig/abi/safe_abi"
+)
+
+const (
+	defaultPollInterval = 12 * time.Second
+	defaultReorgDepth   = 64
+	resubscribeBackoff  = 30 * time.Second
+	notifyTimeout       = 10 * time.Second
+)
+
+// reorgTracker remembers the hashes of recently seen blocks and the events emitted
+// from them, so that events from blocks which left the canonical chain can be retracted.
+type reorgTracker struct {
+	depth  uint64
+	head   uint64
+	hashes map[uint64]string
+	events map[uint64][]safeEvent
+	seen   map[string]bool
+}
+
+func newReorgTracker(depth uint64) *reorgTracker {
+	return &reorgTracker{
+		depth:  depth,
+		head:   0,
+		hashes: map[uint64]string{},
+		events: map[uint64][]safeEvent{},
+		seen:   map[string]bool{},
+	}
+}
+
+// observe records the hash of a block. If another hash was recorded for the same
+// height, every event from that height onwards is retracted and returned.
+func (t *reorgTracker) observe(number uint64, hash string) []safeEvent {
+	var retracted []safeEvent
+
+	known, ok := t.hashes[number]
+	if ok && known != hash {
+		log.Printf("Reorg at block %d: %s replaced by %s", number, known, hash)
+
+		retracted = t.rollback(number)
+	}
+
+	t.hashes[number] = hash
+	t.head = max(t.head, number)
+	t.prune()
+
+	return retracted
+}
+
+// rollback forgets every block from the given height and returns its events marked as removed.
+func (t *reorgTracker) rollback(from uint64) []safeEvent {
+	numbers := make([]uint64, 0, len(t.events))
+	for number := range t.events {
+		if number >= from {
+			numbers = append(numbers, number)
+		}
+	}
+
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+
+	var retracted []safeEvent
+
+	for _, number := range numbers {
+		for _, event := range t.events[number] {
+			delete(t.seen, eventKey(event))
+
+			event.Removed = true
+			retracted = append(retracted, event)
+		}
+
+		delete(t.events, number)
+	}
+
+	for number := range t.hashes {
+		if number >= from {
+			delete(t.hashes, number)
+		}
+	}
+
+	if from > 0 {
+		t.head = min(t.head, from-1)
+	}
+
+	return retracted
+}
+
+// add applies an event delivered by the node and returns the events to emit.
+func (t *reorgTracker) add(event safeEvent) []safeEvent {
+	key := eventKey(event)
+
+	if event.Removed {
+		if !t.seen[key] {
+			return nil
+		}
+
+		delete(t.seen, key)
+
+		kept := t.events[event.BlockNumber][:0]
+		for _, known := range t.events[event.BlockNumber] {
+			if eventKey(known) != key {
+				kept = append(kept, known)
+			}
+		}
+
+		t.events[event.BlockNumber] = kept
+
+		return []safeEvent{event}
+	}
+
+	emitted := t.observe(event.BlockNumber, event.BlockHash)
+	if t.seen[key] {
+		return emitted
+	}
+
+	t.seen[key] = true
+	t.events[event.BlockNumber] = append(t.events[event.BlockNumber], event)
+
+	return append(emitted, event)
+}
+
+// tracked returns the remembered block heights, newest first.
+func (t *reorgTracker) tracked() []uint64 {
+	numbers := make([]uint64, 0, len(t.hashes))
+	for number := range t.hashes {
+		numbers = append(numbers, number)
+	}
+
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] > numbers[j] })
+
+	return numbers
+}
+
+func (t *reorgTracker) prune() {
+	if t.head < t.depth {
+		return
+	}
+
+	oldest := t.head - t.depth
+
+	for number := range t.hashes {
+		if number < oldest {
+			delete(t.hashes, number)
+		}
+	}
+
+	for number, events := range t.events {
+		if number >= oldest {
+			continue
+		}
+
+		for _, event := range events {
+			delete(t.seen, eventKey(event))
+		}
+
+		delete(t.events, number)
+	}
+}
+
+// eventNotifier delivers events as JSON lines to stdout and, when configured,
+// to a webhook and a local command.
+type eventNotifier struct {
+	webhook string
+	command string
+	client  *http.Client
+}
+
+func (n eventNotifier) notify(event safeEvent) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Println("Cannot encode event:", err)
+
+		return
+	}
+
+	fmt.Println(string(payload))
+
+	if n.webhook != "" {
+		err = n.postWebhook(payload)
+		if err != nil {
+			log.Printf("Webhook failed for %s %s: %v", event.Event, event.TxHash, err)
+		}
+	}
+
+	if n.command != "" {
+		err = n.runCommand(event, payload)
+		if err != nil {
+			log.Printf("Command failed for %s %s: %v", event.Event, event.TxHash, err)
+		}
+	}
+}
+
+func (n eventNotifier) postWebhook(payload []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, n.webhook, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := n.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("webhook responded with %s", response.Status)
+	}
+
+	return nil
+}
+
+// runCommand runs the command through the shell with the event as JSON on stdin.
+func (n eventNotifier) runCommand(event safeEvent, payload []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", n.command)
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(),
+		"SAFE_ADDRESS="+event.Safe,
+		"SAFE_EVENT="+event.Event,
+		"SAFE_TX_HASH="+event.TxHash,
+		"SAFE_BLOCK_NUMBER="+strconv.FormatUint(event.BlockNumber, 10),
+		"SAFE_EVENT_REMOVED="+strconv.FormatBool(event.Removed),
+	)
+
+	return cmd.Run()
+}
+
+type safeWatcher struct {
+	provider  *ethclient.Client
+	filterers []*safe_abi.SafeAbiFilterer
+	filters   []safeEventFilter
+	tracker   *reorgTracker
+	notifier  eventNotifier
+}
+
+func (w *safeWatcher) emit(events ...safeEvent) {
+	for _, event := range events {
+		w.notifier.notify(event)
+	}
+}
+
+// poll scans new blocks with the Filter* iterators every interval. Before each scan
+// the remembered block hashes are compared with the chain, newest first, and events
+// from orphaned blocks are retracted and rescanned.
+func (w *safeWatcher) poll(ctx context.Context, next uint64, interval time.Duration) error {
+	for {
+		var err error
+
+		next, err = w.pollOnce(ctx, next)
+		if err != nil {
+			log.Println("Poll failed:", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+func (w *safeWatcher) pollOnce(ctx context.Context, next uint64) (uint64, error) {
+	reorgFrom := uint64(0)
+	reorged := false
+
+	for _, number := range w.tracker.tracked() {
+		header, err := w.provider.HeaderByNumber(ctx, new(big.Int).SetUint64(number))
+		if err != nil {
+			return next, err
+		}
+
+		if header.Hash().Hex() == w.tracker.hashes[number] {
+			if reorged {
+				reorgFrom = number + 1
+			}
+
+			break
+		}
+
+		reorgFrom = number
+		reorged = true
+	}
+
+	if reorged {
+		log.Printf("Reorg detected, rescanning from block %d", reorgFrom)
+		w.emit(w.tracker.rollback(reorgFrom)...)
+
+		next = min(next, reorgFrom)
+	}
+
+	latest, err := w.provider.BlockNumber(ctx)
+	if err != nil {
+		return next, err
+	}
+
+	if next > latest {
+		return next, nil
+	}
+
+	end := min(latest, next+defaultAuditChunk-1)
+
+	// The head hash is read before the logs, so logs served from a block that is
+	// reorged in between are caught by the next hash comparison.
+	header, err := w.provider.HeaderByNumber(ctx, new(big.Int).SetUint64(end))
+	if err != nil {
+		return next, err
+	}
+
+	var events []safeEvent
+
+	for _, filterer := range w.filterers {
+		batch, err := filterSafeEvents(filterer, w.filters, next, end)
+		if err != nil {
+			return next, err
+		}
+
+		events = append(events, batch...)
+	}
+
+	sortSafeEvents(events)
+
+	for _, event := range events {
+		w.emit(w.tracker.add(event)...)
+	}
+
+	w.emit(w.tracker.observe(end, header.Hash().Hex())...)
+
+	return end + 1, nil
+}
+
+// subscribe streams events through the Watch* subscriptions. Every subscription is
+// re-established with backoff when the connection drops; blocks missed meanwhile are
+// backfilled with the Filter* iterators from the last processed block. With backfill
+// set, the first subscription also replays events from the start block.
+func (w *safeWatcher) subscribe(ctx context.Context, start uint64, backfill bool) error {
+	sink := make(chan safeEvent)
+
+	var last atomic.Uint64
+	last.Store(start)
+
+	subscriptions := make([]event.Subscription, 0, len(w.filterers)*len(w.filters))
+
+	for _, filterer := range w.filterers {
+		for _, f := range w.filters {
+			sub := event.ResubscribeErr(resubscribeBackoff, func(ctx context.Context, lastErr error) (event.Subscription, error) {
+				if lastErr != nil {
+					log.Printf("%s subscription dropped: %v", f.name, lastErr)
+				}
+
+				sub, err := f.watch(filterer, &bind.WatchOpts{Start: nil, Context: ctx}, sink)
+				if err != nil {
+					return nil, err
+				}
+
+				if lastErr == nil && !backfill {
+					return sub, nil
+				}
+
+				events, err := f.filter(filterer, &bind.FilterOpts{Start: last.Load(), End: nil, Context: ctx})
+				if err != nil {
+					sub.Unsubscribe()
+
+					return nil, err
+				}
+
+				for _, backfilled := range events {
+					select {
+					case sink <- backfilled:
+					case <-ctx.Done():
+						sub.Unsubscribe()
+
+						return nil, ctx.Err()
+					}
+				}
+
+				return sub, nil
+			})
+
+			subscriptions = append(subscriptions, sub)
+		}
+	}
+
+	defer func() {
+		for _, sub := range subscriptions {
+			sub.Unsubscribe()
+		}
+	}()
+
+	for {
+		select {
+		case received := <-sink:
+			w.emit(w.tracker.add(received)...)
+			last.Store(w.tracker.head)
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// supportsSubscriptions reports whether the RPC endpoint accepts eth_subscribe.
+func supportsSubscriptions(ctx context.Context, provider *ethclient.Client) (bool, error) {
+	sub, err := provider.SubscribeNewHead(ctx, make(chan *types.Header))
+	if errors.Is(err, rpc.ErrNotificationsUnsupported) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	sub.Unsubscribe()
+
+	return true, nil
+}
+
+func parseEventFilters(names []string) ([]safeEventFilter, error) {
+	if len(names) == 0 {
+		return safeEventFilters, nil
+	}
+
+	filters := make([]safeEventFilter, 0, len(names))
+
+	for _, name := range names {
+		found := false
+
+		for _, f := range safeEventFilters {
+			if f.name == name {
+				filters = append(filters, f)
+				found = true
+
+				break
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("unknown event %q", name)
+		}
+	}
+
+	return filters, nil
+}
+
+func watchCommand() *command {
+	return &command{
+		name:        "watch",
+		args:        "<safe> [safe...]",
+		description: "Stream Safe events as JSON lines and alert on changes",
+		run: func(fs *flag.FlagSet, args []string) error {
+			var events stringList
+
+			fs.Var(&events, "event", "Event to watch, e.g. AddedOwner (repeatable, default all)")
+			from := fs.Uint64("from", 0, "Backfill events starting from this block (default only new events)")
+			webhook := fs.String("webhook", "", "POST every event as JSON to this URL")
+			execCommand := fs.String("exec", "", "Run this shell command for every event with the event JSON on stdin")
+			poll := fs.Bool("poll", false, "Poll with eth_getLogs even if the RPC supports subscriptions")
+			interval := fs.Duration("interval", defaultPollInterval, "Polling interval")
+			depth := fs.Uint64("reorg-depth", defaultReorgDepth, "Number of recent blocks whose hashes are tracked for reorgs")
+
+			positional, err := parseVarArgs(fs, args, 1)
+			if err != nil {
+				return err
+			}
+
+			filters, err := parseEventFilters(events)
+			if err != nil {
+				return err
+			}
+
+			provider, err := getProvider()
+			if err != nil {
+				return err
+			}
+
+			filterers := make([]*safe_abi.SafeAbiFilterer, 0, len(positional))
+			safes := make(map[common.Address]bool, len(positional))
+
+			for _, value := range positional {
+				safe, err := parseAddress(value)
+				if err != nil {
+					return err
+				}
+
+				if safes[safe] {
+					return fmt.Errorf("safe %s is listed twice", safe.Hex())
+				}
+
+				safes[safe] = true
+
+				err = checkEventVersion(provider, safe)
+				if err != nil {
+					return err
+				}
+
+				filterer, err := safe_abi.NewSafeAbiFilterer(safe, provider)
+				if err != nil {
+					return err
+				}
+
+				filterers = append(filterers, filterer)
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			watcher := &safeWatcher{
+				provider:  provider,
+				filterers: filterers,
+				filters:   filters,
+				tracker:   newReorgTracker(*depth),
+				notifier: eventNotifier{
+					webhook: *webhook,
+					command: *execCommand,
+					client:  &http.Client{Timeout: notifyTimeout}, //nolint:exhaustruct
+				},
+			}
+
+			subscriptions := false
+			if !*poll {
+				subscriptions, err = supportsSubscriptions(ctx, provider)
+				if err != nil {
+					return err
+				}
+			}
+
+			start := *from
+			if start == 0 {
+				latest, err := provider.BlockNumber(ctx)
+				if err != nil {
+					return err
+				}
+
+				start = latest + 1
+			}
+
+			if subscriptions {
+				log.Printf("Watching %d safe(s) via subscriptions", len(filterers))
+
+				if *from == 0 {
+					// Reconnects backfill from the last processed block, which is the current head.
+					return watcher.subscribe(ctx, start-1, false)
+				}
+
+				return watcher.subscribe(ctx, start, true)
+			}
+
+			log.Printf("Watching %d safe(s) by polling every %s from block %d", len(filterers), *interval, start)
+
+			return watcher.poll(ctx, start, *interval)
+		},
+		subcommands: nil,
+	}
+}